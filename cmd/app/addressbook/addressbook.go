@@ -0,0 +1,207 @@
+package addressbook
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"task/cmd/app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 审计动作
+const (
+	ActionAllowAdd    = "allow_add"
+	ActionAllowRemove = "allow_remove"
+	ActionBlockAdd    = "block_add"
+	ActionBlockRemove = "block_remove"
+)
+
+// Policy 收款地址不在白名单（或仍在冷静期）时的处理方式
+type Policy string
+
+const (
+	PolicyReject        Policy = "reject"         // 拒绝
+	PolicyExtraApproval Policy = "extra_approval" // 需要额外审批
+)
+
+// Decision 对收款地址的判定结果
+type Decision uint8
+
+const (
+	DecisionAllow         Decision = iota // 白名单地址，按默认审批数
+	DecisionExtraApproval                 // 非白名单地址，需要额外审批
+	DecisionReject                        // 拒绝
+)
+
+// ErrAlreadyAllowed 地址已在白名单中
+var ErrAlreadyAllowed = errors.New("address already allowlisted")
+
+// RejectedError 收款地址被拒绝
+type RejectedError struct {
+	Address string
+	Reason  string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("destination rejected: address=%s, reason=%s", e.Address, e.Reason)
+}
+
+// Config 地址簿配置
+type Config struct {
+	CoolingOff       time.Duration // 白名单地址加入后的冷静期
+	Policy           Policy        // 非白名单地址的处理方式
+	DefaultApprovals uint64        // 白名单地址所需审批数
+	ExtraApprovals   uint64        // 非白名单地址所需审批数
+}
+
+// Book 收款地址白名单和黑名单
+// 所有变更都写入 address_audits，与变更本身在同一个事务中提交。
+type Book struct {
+	db  *gorm.DB
+	cfg Config
+	now func() time.Time
+}
+
+func New(db *gorm.DB, cfg Config) *Book {
+	if cfg.Policy == "" {
+		cfg.Policy = PolicyExtraApproval
+	}
+	if cfg.DefaultApprovals == 0 {
+		cfg.DefaultApprovals = 2
+	}
+	if cfg.ExtraApprovals < cfg.DefaultApprovals {
+		cfg.ExtraApprovals = cfg.DefaultApprovals + 1
+	}
+	return &Book{db: db, cfg: cfg, now: time.Now}
+}
+
+// Check 判定收款地址，返回所需审批数；黑名单地址、或策略为拒绝时的非白名单地址返回 *RejectedError
+// db 可以是调用方的事务。
+func (b *Book) Check(db *gorm.DB, address string) (uint64, error) {
+	var blocked model.BlockedAddress
+	err := db.Where("address = ?", address).Limit(1).Find(&blocked).Error
+	if err != nil {
+		return 0, err
+	}
+	if blocked.ID != 0 {
+		return 0, &RejectedError{Address: address, Reason: "blocklisted"}
+	}
+
+	var allowed model.AllowedAddress
+	err = db.Where("address = ?", address).Limit(1).Find(&allowed).Error
+	if err != nil {
+		return 0, err
+	}
+	if allowed.ID != 0 && !b.now().Before(allowed.ActiveAt) {
+		return b.cfg.DefaultApprovals, nil
+	}
+
+	if b.cfg.Policy == PolicyReject {
+		reason := "not allowlisted"
+		if allowed.ID != 0 {
+			reason = "allowlist cooling-off period"
+		}
+		return 0, &RejectedError{Address: address, Reason: reason}
+	}
+	return b.cfg.ExtraApprovals, nil
+}
+
+// Allow 加入白名单，冷静期从现在开始计算；已在白名单中时返回 ErrAlreadyAllowed，不重新计算冷静期
+func (b *Book) Allow(address, label string, actorID uint64) (*model.AllowedAddress, error) {
+	entry := &model.AllowedAddress{
+		Address:   address,
+		Label:     label,
+		ActiveAt:  b.now().Add(b.cfg.CoolingOff),
+		CreatedBy: actorID,
+	}
+	err := b.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(entry)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAlreadyAllowed
+		}
+		return audit(tx, ActionAllowAdd, address, actorID, label)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Disallow 移出白名单
+func (b *Book) Disallow(address string, actorID uint64) error {
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("address = ?", address).Delete(&model.AllowedAddress{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return audit(tx, ActionAllowRemove, address, actorID, "")
+	})
+}
+
+// Block 加入黑名单，已存在时更新原因和来源
+func (b *Book) Block(entries []BlockEntry, source string, actorID uint64) (int, error) {
+	added := 0
+	err := b.db.Transaction(func(tx *gorm.DB) error {
+		for _, e := range entries {
+			var existing model.BlockedAddress
+			err := tx.Where("address = ?", e.Address).Limit(1).Find(&existing).Error
+			if err != nil {
+				return err
+			}
+			if existing.ID == 0 {
+				err = tx.Create(&model.BlockedAddress{Address: e.Address, Reason: e.Reason, Source: source}).Error
+				added++
+			} else {
+				err = tx.Model(&existing).Updates(map[string]interface{}{"reason": e.Reason, "source": source}).Error
+			}
+			if err != nil {
+				return err
+			}
+			err = audit(tx, ActionBlockAdd, e.Address, actorID, fmt.Sprintf("source=%s reason=%s", source, e.Reason))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return added, err
+}
+
+// Unblock 移出黑名单
+func (b *Book) Unblock(address string, actorID uint64) error {
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("address = ?", address).Delete(&model.BlockedAddress{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return audit(tx, ActionBlockRemove, address, actorID, "")
+	})
+}
+
+// IsRejected 是否是收款地址被拒绝的错误
+func IsRejected(err error) (*RejectedError, bool) {
+	var rejected *RejectedError
+	ok := errors.As(err, &rejected)
+	return rejected, ok
+}
+
+func audit(tx *gorm.DB, action, address string, actorID uint64, detail string) error {
+	return tx.Create(&model.AddressAudit{
+		Action:  action,
+		Address: address,
+		ActorID: actorID,
+		Detail:  detail,
+	}).Error
+}
@@ -0,0 +1,57 @@
+package addressbook
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"task/cmd/app/migrate"
+	"task/cmd/app/model"
+
+	"gorm.io/gorm"
+)
+
+func openSQLite(t *testing.T) *gorm.DB {
+	db, err := model.Open(model.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.New(db)
+	if err == nil {
+		_, err = m.Up(context.Background())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// 重复加入白名单返回 ErrAlreadyAllowed，不重新计算冷静期，也不写审计记录
+func TestAllowTwice(t *testing.T) {
+	db := openSQLite(t)
+	book := New(db, Config{CoolingOff: time.Hour})
+
+	entry, err := book.Allow(addrA, "exchange", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	book.now = func() time.Time { return time.Now().Add(time.Minute * 30) }
+	if _, err = book.Allow(addrA, "again", 2); !errors.Is(err, ErrAlreadyAllowed) {
+		t.Fatalf("expected ErrAlreadyAllowed, got %v", err)
+	}
+
+	var allowed model.AllowedAddress
+	if err = db.Take(&allowed, "address = ?", addrA).Error; err != nil {
+		t.Fatal(err)
+	}
+	if allowed.Label != "exchange" || !allowed.ActiveAt.Equal(entry.ActiveAt) {
+		t.Fatalf("entry changed: %+v", allowed)
+	}
+	var audits int64
+	if err = db.Model(&model.AddressAudit{}).Where("address = ?", addrA).Count(&audits).Error; err != nil || audits != 1 {
+		t.Fatalf("audits=%d, err=%v", audits, err)
+	}
+}
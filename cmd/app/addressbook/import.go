@@ -0,0 +1,122 @@
+package addressbook
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"task/cmd/app/eth"
+)
+
+// BlockEntry 待加入黑名单的地址
+type BlockEntry struct {
+	Address string `json:"address"`
+	Reason  string `json:"reason"`
+}
+
+// ParseBlocklist 解析制裁名单文件，format 为 csv 或 json
+//
+// CSV 第一行为表头，必须包含 address 列，可选 reason 列；
+// JSON 可以是地址字符串数组，或 {"address": "...", "reason": "..."} 对象数组。
+// 地址统一转换为校验和格式，重复地址只保留一条。
+func ParseBlocklist(r io.Reader, format string) ([]BlockEntry, error) {
+	var entries []BlockEntry
+	var err error
+	switch format {
+	case "csv":
+		entries, err = parseCSV(r)
+	case "json":
+		entries, err = parseJSON(r)
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	result := make([]BlockEntry, 0, len(entries))
+	for i, e := range entries {
+		addr, err := eth.ParseAddress(strings.TrimSpace(e.Address))
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		e.Address = addr.String()
+		if seen[e.Address] {
+			continue
+		}
+		seen[e.Address] = true
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func parseCSV(r io.Reader) ([]BlockEntry, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	addressCol, reasonCol := -1, -1
+	for i, h := range header {
+		switch strings.ToLower(strings.TrimSpace(h)) {
+		case "address":
+			addressCol = i
+		case "reason":
+			reasonCol = i
+		}
+	}
+	if addressCol < 0 {
+		return nil, errors.New("csv header has no address column")
+	}
+
+	var entries []BlockEntry
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if addressCol >= len(record) || strings.TrimSpace(record[addressCol]) == "" {
+			continue
+		}
+		e := BlockEntry{Address: record[addressCol]}
+		if reasonCol >= 0 && reasonCol < len(record) {
+			e.Reason = record[reasonCol]
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func parseJSON(r io.Reader) ([]BlockEntry, error) {
+	var raw []json.RawMessage
+	err := json.NewDecoder(r).Decode(&raw)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]BlockEntry, 0, len(raw))
+	for _, item := range raw {
+		var address string
+		if json.Unmarshal(item, &address) == nil {
+			entries = append(entries, BlockEntry{Address: address})
+			continue
+		}
+		var e BlockEntry
+		err = json.Unmarshal(item, &e)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
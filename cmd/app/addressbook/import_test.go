@@ -0,0 +1,56 @@
+package addressbook
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	addrA = "0x23AC5dEDa8a5C6D9b4721b05E7882bE718E5C07d"
+	addrB = "0xBF5e18bCdA7e9189B92EF17a5dd7E7e4767dBc36"
+)
+
+func TestParseBlocklistCSV(t *testing.T) {
+	data := "name,address,reason\n" +
+		"a," + strings.ToLower(addrA) + ",sanctioned\n" +
+		"b," + addrB + ",\n" +
+		"dup," + addrA + ",again\n"
+
+	entries, err := ParseBlocklist(strings.NewReader(data), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries=%+v", entries)
+	}
+	if entries[0].Address != addrA || entries[0].Reason != "sanctioned" || entries[1].Address != addrB {
+		t.Fatalf("entries=%+v", entries)
+	}
+}
+
+func TestParseBlocklistJSON(t *testing.T) {
+	data := `["` + addrA + `", {"address": "` + addrB + `", "reason": "ofac"}]`
+
+	entries, err := ParseBlocklist(strings.NewReader(data), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[1].Reason != "ofac" {
+		t.Fatalf("entries=%+v", entries)
+	}
+}
+
+func TestParseBlocklistInvalid(t *testing.T) {
+	cases := map[string]string{
+		"csv":  "address\n0x1234\n",
+		"json": `["not an address"]`,
+	}
+	for format, data := range cases {
+		if _, err := ParseBlocklist(strings.NewReader(data), format); err == nil {
+			t.Errorf("%s: expected error", format)
+		}
+	}
+	if _, err := ParseBlocklist(strings.NewReader("reason\nx\n"), "csv"); err == nil {
+		t.Errorf("missing address column: expected error")
+	}
+}
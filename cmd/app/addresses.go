@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"task/cmd/app/addressbook"
+	"task/cmd/app/apierr"
+	"task/cmd/app/auth"
+	"task/cmd/app/eth"
+	"task/cmd/app/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AllowAddressRequest struct {
+	Address string `json:"address"`
+	Label   string `json:"label"`
+}
+
+type BlockAddressRequest struct {
+	Address string `json:"address"`
+	Reason  string `json:"reason"`
+}
+
+// registerAddressRoutes 注册收款地址白名单、黑名单路由
+// 变更需要 manage-addresses 权限，查询需要 read-all 权限。
+func registerAddressRoutes(r *gin.Engine, db *gorm.DB, book *addressbook.Book) {
+	manage := r.Group("/address", auth.Require(auth.PermManageAddresses))
+	read := r.Group("/address", auth.Require(auth.PermReadAll))
+
+	// 加入白名单 (POST /address/allow)
+	manage.POST("/allow", func(c *gin.Context) {
+		req := &AllowAddressRequest{}
+		err := c.ShouldBindJSON(req)
+		if err != nil {
+			apierr.Respond(c, apierr.InvalidRequest(err))
+			return
+		}
+		addr, err := eth.ParseAddress(req.Address)
+		if err != nil {
+			apierr.Respond(c, apierr.Field(apierr.CodeInvalidAddress, "address", "must be a hex address with a valid checksum", err))
+			return
+		}
+
+		entry, err := book.Allow(addr.String(), req.Label, auth.IdentityFrom(c).UserID)
+		if errors.Is(err, addressbook.ErrAlreadyAllowed) {
+			apierr.Respond(c, apierr.Wrap(apierr.CodeAddressAlreadyAllowed, "address already allowlisted", err))
+			return
+		}
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("allow address failed", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "success",
+			"address": entry,
+		})
+	})
+
+	// 移出白名单 (DELETE /address/allow/{address})
+	manage.DELETE("/allow/:address", func(c *gin.Context) {
+		addr, err := eth.ParseAddress(c.Param("address"))
+		if err == nil {
+			err = book.Disallow(addr.String(), auth.IdentityFrom(c).UserID)
+		}
+		respondAddressChange(c, err)
+	})
+
+	// 加入黑名单 (POST /address/block)
+	manage.POST("/block", func(c *gin.Context) {
+		req := &BlockAddressRequest{}
+		err := c.ShouldBindJSON(req)
+		if err != nil {
+			apierr.Respond(c, apierr.InvalidRequest(err))
+			return
+		}
+		addr, err := eth.ParseAddress(req.Address)
+		if err == nil {
+			entries := []addressbook.BlockEntry{{Address: addr.String(), Reason: req.Reason}}
+			_, err = book.Block(entries, "manual", auth.IdentityFrom(c).UserID)
+		}
+		respondAddressChange(c, err)
+	})
+
+	// 导入制裁名单 (POST /address/block/import?format=csv|json&source=name)
+	manage.POST("/block/import", func(c *gin.Context) {
+		format := c.Query("format")
+		if format == "" {
+			format = "csv"
+			if strings.Contains(c.ContentType(), "json") {
+				format = "json"
+			}
+		}
+		source := c.DefaultQuery("source", "import")
+
+		entries, err := addressbook.ParseBlocklist(c.Request.Body, format)
+		if err != nil {
+			apierr.Respond(c, apierr.Field(apierr.CodeValidationFailed, "blocklist", err.Error(), err))
+			return
+		}
+
+		added, err := book.Block(entries, source, auth.IdentityFrom(c).UserID)
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("import blocklist failed", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "success",
+			"total":   len(entries),
+			"added":   added,
+		})
+	})
+
+	// 移出黑名单 (DELETE /address/block/{address})
+	manage.DELETE("/block/:address", func(c *gin.Context) {
+		addr, err := eth.ParseAddress(c.Param("address"))
+		if err == nil {
+			err = book.Unblock(addr.String(), auth.IdentityFrom(c).UserID)
+		}
+		respondAddressChange(c, err)
+	})
+
+	// 查询白名单和黑名单 (GET /address/list)
+	read.GET("/list", func(c *gin.Context) {
+		var allowed []*model.AllowedAddress
+		var blocked []*model.BlockedAddress
+		err := db.Order("id").Find(&allowed).Error
+		if err == nil {
+			err = db.Order("id").Find(&blocked).Error
+		}
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("find addresses failed", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"allowed": allowed,
+			"blocked": blocked,
+		})
+	})
+
+	// 查询变更记录 (GET /address/audit)
+	read.GET("/audit", func(c *gin.Context) {
+		var audits []*model.AddressAudit
+		err := db.Order("id desc").Limit(1000).Find(&audits).Error
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("find address audits failed", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"audits": audits,
+		})
+	})
+}
+
+func respondAddressChange(c *gin.Context, err error) {
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{
+			"message": "success",
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		apierr.Respond(c, apierr.Wrap(apierr.CodeAddressNotFound, "address not found", err))
+	case errors.Is(err, eth.ErrInvalidAddress):
+		apierr.Respond(c, apierr.Field(apierr.CodeInvalidAddress, "address", "must be a hex address with a valid checksum", err))
+	default:
+		apierr.Respond(c, apierr.Internal("change address failed", err))
+	}
+}
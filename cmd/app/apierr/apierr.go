@@ -0,0 +1,194 @@
+package apierr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"task/cmd/app/requestid"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Code 稳定的错误码，调用方应按错误码而不是 message 处理错误
+type Code string
+
+const (
+	CodeInvalidRequest        Code = "INVALID_REQUEST"         // 请求格式错误
+	CodeValidationFailed      Code = "VALIDATION_FAILED"       // 字段校验失败，见 details
+	CodeInvalidAmount         Code = "INVALID_AMOUNT"          // 金额不合法
+	CodeInvalidAddress        Code = "INVALID_ADDRESS"         // 地址不合法
+	CodeUnauthorized          Code = "UNAUTHORIZED"            // 未认证
+	CodeForbidden             Code = "FORBIDDEN"               // 没有权限
+	CodeSelfApproval          Code = "SELF_APPROVAL_FORBIDDEN" // 发起人不能审批自己的提款申请
+	CodeWithdrawalNotFound    Code = "WITHDRAWAL_NOT_FOUND"    // 提款申请不存在
+	CodeApprovalNotFound      Code = "APPROVAL_NOT_FOUND"      // 没有该经理的审批记录
+	CodeAlreadyExecuted       Code = "ALREADY_EXECUTED"        // 已经上链成功
+	CodeInsufficientApprovals Code = "INSUFFICIENT_APPROVALS"  // 审批数不足
+	CodeWithdrawalRejected    Code = "WITHDRAWAL_REJECTED"     // 提款申请已拒绝
+	CodeWithdrawalExpired     Code = "WITHDRAWAL_EXPIRED"      // 提款申请已过期
+	CodeInvalidState          Code = "INVALID_STATE"           // 当前状态不允许该操作
+	CodeConcurrentUpdate      Code = "CONCURRENT_UPDATE"       // 提款申请已被其他请求修改，可以重试
+	CodeDestinationRejected   Code = "DESTINATION_REJECTED"    // 收款地址被拒绝
+	CodeLimitExceeded         Code = "LIMIT_EXCEEDED"          // 超出限额
+	CodeUserNotFound          Code = "USER_NOT_FOUND"          // 用户不存在
+	CodeAddressNotFound       Code = "ADDRESS_NOT_FOUND"       // 地址不在名单中
+	CodeAddressAlreadyAllowed Code = "ADDRESS_ALREADY_ALLOWED" // 地址已在白名单中
+	CodeWebhookNotFound       Code = "WEBHOOK_NOT_FOUND"       // webhook 订阅不存在
+	CodeDeadLetterNotFound    Code = "DEAD_LETTER_NOT_FOUND"   // 死信不存在
+	CodeAccountNotFound       Code = "ACCOUNT_NOT_FOUND"       // 账本账户不存在
+	CodeInternal              Code = "INTERNAL_ERROR"          // 服务端错误
+)
+
+// statuses 错误码对应的 HTTP 状态码
+var statuses = map[Code]int{
+	CodeInvalidRequest:        http.StatusBadRequest,
+	CodeValidationFailed:      http.StatusBadRequest,
+	CodeInvalidAmount:         http.StatusBadRequest,
+	CodeInvalidAddress:        http.StatusBadRequest,
+	CodeUnauthorized:          http.StatusUnauthorized,
+	CodeForbidden:             http.StatusForbidden,
+	CodeSelfApproval:          http.StatusForbidden,
+	CodeWithdrawalNotFound:    http.StatusNotFound,
+	CodeApprovalNotFound:      http.StatusNotFound,
+	CodeAlreadyExecuted:       http.StatusConflict,
+	CodeInsufficientApprovals: http.StatusConflict,
+	CodeWithdrawalRejected:    http.StatusConflict,
+	CodeWithdrawalExpired:     http.StatusConflict,
+	CodeInvalidState:          http.StatusConflict,
+	CodeConcurrentUpdate:      http.StatusConflict,
+	CodeDestinationRejected:   http.StatusForbidden,
+	CodeLimitExceeded:         http.StatusUnprocessableEntity,
+	CodeUserNotFound:          http.StatusNotFound,
+	CodeAddressNotFound:       http.StatusNotFound,
+	CodeAddressAlreadyAllowed: http.StatusConflict,
+	CodeWebhookNotFound:       http.StatusNotFound,
+	CodeDeadLetterNotFound:    http.StatusNotFound,
+	CodeAccountNotFound:       http.StatusNotFound,
+	CodeInternal:              http.StatusInternalServerError,
+}
+
+// Status 错误码对应的 HTTP 状态码
+func (c Code) Status() int {
+	if status, ok := statuses[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// FieldError 字段级校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error 接口错误，REST 和 gRPC 都按 Code 映射状态码
+type Error struct {
+	Code    Code                   // 错误码
+	Message string                 // 返回给调用方的信息
+	Details []FieldError           // 字段级校验错误
+	Meta    map[string]interface{} // 附加信息，如触发的限额
+	Err     error                  // 原始错误，只记录日志
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status HTTP 状态码
+func (e *Error) Status() int {
+	return e.Code.Status()
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap 附加原始错误
+func Wrap(code Code, message string, err error) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+// Field 单个字段校验失败
+func Field(code Code, field, message string, err error) *Error {
+	return &Error{
+		Code:    code,
+		Message: "invalid " + field,
+		Details: []FieldError{{Field: field, Message: message}},
+		Err:     err,
+	}
+}
+
+// InvalidRequest 请求格式错误
+func InvalidRequest(err error) *Error {
+	return Wrap(CodeInvalidRequest, "invalid request", err)
+}
+
+// Internal 服务端错误，message 描述失败的操作，原始错误不返回给调用方
+func Internal(message string, err error) *Error {
+	return Wrap(CodeInternal, message, err)
+}
+
+// ConcurrentUpdate 并发修改冲突，meta 中的 retryable 提示调用方可以直接重试
+func ConcurrentUpdate(err error) *Error {
+	return Wrap(CodeConcurrentUpdate, "withdrawal modified concurrently, retry", err).WithMeta("retryable", true)
+}
+
+// WithMeta 添加附加信息
+func (e *Error) WithMeta(key string, value interface{}) *Error {
+	if e.Meta == nil {
+		e.Meta = make(map[string]interface{})
+	}
+	e.Meta[key] = value
+	return e
+}
+
+// From 未分类的错误按 INTERNAL_ERROR 处理
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal("internal error", err)
+}
+
+// Body 错误响应体
+type Body struct {
+	Code      Code                   `json:"code"`
+	Message   string                 `json:"message"`
+	Details   []FieldError           `json:"details,omitempty"`
+	Meta      map[string]interface{} `json:"meta,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+}
+
+// Response 错误响应，REST 和 gRPC 共用
+func Response(err error, requestID string) (int, *Body) {
+	e := From(err)
+	level := slog.LevelWarn
+	if e.Status() >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	slog.Log(context.Background(), level, "request failed", "request_id", requestID, "code", e.Code, "message", e.Message, "err", e.Err)
+	return e.Status(), &Body{
+		Code:      e.Code,
+		Message:   e.Message,
+		Details:   e.Details,
+		Meta:      e.Meta,
+		RequestID: requestID,
+	}
+}
+
+// Respond 返回错误响应并终止后续 handler
+func Respond(c *gin.Context, err error) {
+	status, body := Response(err, requestid.From(c.Request.Context()))
+	c.AbortWithStatusJSON(status, body)
+}
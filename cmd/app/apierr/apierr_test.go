@@ -0,0 +1,59 @@
+package apierr
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"task/cmd/app/requestid"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestResponse(t *testing.T) {
+	status, body := Response(Field(CodeValidationFailed, "name", "is required", nil), "req-1")
+	if status != http.StatusBadRequest || body.Code != CodeValidationFailed || body.RequestID != "req-1" {
+		t.Fatalf("unexpected response: %d %+v", status, body)
+	}
+	if len(body.Details) != 1 || body.Details[0].Field != "name" {
+		t.Fatalf("unexpected details: %+v", body.Details)
+	}
+
+	// 未分类的错误不返回原始错误信息
+	status, body = Response(errors.New("connection refused"), "")
+	if status != http.StatusInternalServerError || body.Code != CodeInternal || body.Message != "internal error" {
+		t.Fatalf("unexpected response: %d %+v", status, body)
+	}
+
+	if Code("UNKNOWN").Status() != http.StatusInternalServerError {
+		t.Fatal("unknown code should map to 500")
+	}
+}
+
+func TestRespond(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(requestid.Middleware())
+	r.GET("/", func(c *gin.Context) {
+		Respond(c, New(CodeWithdrawalNotFound, "withdrawal not found").WithMeta("request_id", 1))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(requestid.Header, "req-2")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound || w.Header().Get(requestid.Header) != "req-2" {
+		t.Fatalf("unexpected response: %d %v", w.Code, w.Header())
+	}
+	body := &Body{}
+	err := json.Unmarshal(w.Body.Bytes(), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != CodeWithdrawalNotFound || body.RequestID != "req-2" || body.Meta["request_id"] == nil {
+		t.Fatalf("unexpected body: %+v", body)
+	}
+}
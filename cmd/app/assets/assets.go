@@ -0,0 +1,86 @@
+package assets
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"task/cmd/app/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// Asset 可提款的资产
+type Asset struct {
+	Symbol   string
+	Decimals int32           // 最小单位的小数位数，ETH 为 18（wei）
+	Max      decimal.Decimal // 单笔金额上限，防止输入错误，业务限额见 limits 包
+}
+
+// ETH 以太坊原生资产
+var ETH = &Asset{
+	Symbol:   model.AssetETH,
+	Decimals: 18,
+	Max:      decimal.NewFromInt(1_000_000),
+}
+
+var all = map[string]*Asset{
+	ETH.Symbol: ETH,
+}
+
+// maxBaseUnits 链上金额是 uint256
+var maxBaseUnits = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// ErrUnknownAsset 不支持的资产
+var ErrUnknownAsset = errors.New("unknown asset")
+
+// Lookup 按符号查询资产，不区分大小写，为空时返回 ETH
+func Lookup(symbol string) (*Asset, error) {
+	if symbol == "" {
+		return ETH, nil
+	}
+	asset, ok := all[strings.ToUpper(symbol)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAsset, symbol)
+	}
+	return asset, nil
+}
+
+// ParseAmount 解析金额并转换为最小单位，返回的错误信息可以直接返回给调用方
+// 金额必须大于 0、不超过单笔上限，且能精确转换为最小单位（小数位数不超过 Decimals）。
+func (a *Asset) ParseAmount(s string) (decimal.Decimal, *big.Int, error) {
+	amount, err := decimal.NewFromString(strings.TrimSpace(s))
+	if err != nil {
+		return decimal.Zero, nil, errors.New("must be a decimal number")
+	}
+	base, err := a.ToBaseUnits(amount)
+	if err != nil {
+		return decimal.Zero, nil, err
+	}
+	return amount, base, nil
+}
+
+// ToBaseUnits 转换为最小单位，不能精确转换或超出范围时返回错误
+func (a *Asset) ToBaseUnits(amount decimal.Decimal) (*big.Int, error) {
+	if !amount.IsPositive() {
+		return nil, errors.New("must be greater than 0")
+	}
+	if amount.GreaterThan(a.Max) {
+		return nil, fmt.Errorf("must not exceed %s %s", a.Max, a.Symbol)
+	}
+	base := amount.Shift(a.Decimals)
+	if !base.IsInteger() {
+		return nil, fmt.Errorf("must have at most %d decimal places", a.Decimals)
+	}
+	wei := base.BigInt()
+	if wei.Cmp(maxBaseUnits) > 0 {
+		return nil, errors.New("out of range")
+	}
+	return wei, nil
+}
+
+// FromBaseUnits 最小单位转换为显示金额
+func (a *Asset) FromBaseUnits(base *big.Int) decimal.Decimal {
+	return decimal.NewFromBigInt(base, -a.Decimals)
+}
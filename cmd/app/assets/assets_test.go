@@ -0,0 +1,50 @@
+package assets
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		amount string
+		wei    string // 为空表示不合法
+	}{
+		{"1", "1000000000000000000"},
+		{"1.5", "1500000000000000000"},
+		{"0.000000000000000001", "1"},
+		{"1.000000000000000000000", "1000000000000000000"}, // 末尾的 0 不影响精度
+		{"1e-3", "1000000000000000"},
+		{"0.0000000000000000001", ""}, // 小于 1 wei
+		{"1e30", ""},                  // 超出上限
+		{"1000000.000000000000000001", ""},
+		{"0", ""},
+		{"-1", ""},
+		{"abc", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		_, wei, err := ETH.ParseAmount(tt.amount)
+		if tt.wei == "" {
+			if err == nil {
+				t.Errorf("%q: expected error, got %s", tt.amount, wei)
+			}
+			continue
+		}
+		if err != nil || wei.String() != tt.wei {
+			t.Errorf("%q: expected %s, got %v, err=%v", tt.amount, tt.wei, wei, err)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if asset, err := Lookup("eth"); err != nil || asset != ETH {
+		t.Fatalf("expected ETH, got %v, err=%v", asset, err)
+	}
+	if asset, err := Lookup(""); err != nil || asset != ETH {
+		t.Fatalf("expected ETH, got %v, err=%v", asset, err)
+	}
+	if _, err := Lookup("BTC"); !errors.Is(err, ErrUnknownAsset) {
+		t.Fatalf("expected ErrUnknownAsset, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"task/cmd/app/model"
+
+	"gorm.io/gorm"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+	apiKeyPrefix = "tk_"
+)
+
+// APIKeyAuthenticator 通过 X-API-Key 请求头认证
+// 数据库只保存 key 的 SHA-256，泄露数据库不会泄露 key。
+type APIKeyAuthenticator struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyAuthenticator(db *gorm.DB) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{db: db}
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	key := r.Header.Get(apiKeyHeader)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	var apiKey model.APIKey
+	err := a.db.Where("key_hash = ?", HashAPIKey(key)).
+		Where("revoked_at IS NULL").
+		First(&apiKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{
+		UserID: apiKey.UserID,
+		Method: "api_key",
+	}, nil
+}
+
+// GenerateAPIKey 为用户生成新的 API key
+// 明文只在此时返回一次，数据库中只保存哈希。
+func GenerateAPIKey(db *gorm.DB, userID uint64, name string) (string, *model.APIKey, error) {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", nil, err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(buf)
+
+	apiKey := &model.APIKey{
+		UserID:  userID,
+		Name:    name,
+		KeyHash: HashAPIKey(key),
+	}
+	err = db.Create(apiKey).Error
+	if err != nil {
+		return "", nil, err
+	}
+	return key, apiKey, nil
+}
+
+// RevokeAPIKey 吊销 API key
+func RevokeAPIKey(db *gorm.DB, id uint) error {
+	return db.Model(&model.APIKey{}).
+		Where("id = ?", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+// HashAPIKey 计算 API key 的 SHA-256
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"task/cmd/app/apierr"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// ErrNoCredentials 请求中没有该认证方式的凭证，交给下一个认证器处理
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials 凭证无效、过期或已吊销
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Identity 通过认证的调用方身份
+type Identity struct {
+	UserID uint64 `json:"user_id"` // 用户 ID，审批时即经理 ID
+	Method string `json:"method"`  // 认证方式 api_key / jwt
+	Roles  []Role `json:"roles"`   // 角色，由 Middleware 通过 RoleStore 加载
+}
+
+// Authenticator 从请求中解析身份
+// 请求中没有对应凭证时返回 ErrNoCredentials。
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+const identityKey = "auth.identity"
+
+// Authenticate 依次尝试各认证器，返回通过认证的身份
+// 全部没有凭证时返回 ErrNoCredentials，凭证无效时返回 ErrInvalidCredentials；
+// roles 不为 nil 时，认证通过后加载身份的角色，加载失败时返回其他错误。
+// REST 中间件和 gRPC 共用，gRPC 把 metadata 转换为请求头后调用。
+func Authenticate(r *http.Request, roles RoleStore, authenticators ...Authenticator) (*Identity, error) {
+	for _, a := range authenticators {
+		identity, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), "authenticate failed", "err", err)
+			return nil, ErrInvalidCredentials
+		}
+
+		if roles != nil {
+			identity.Roles, err = roles.Roles(identity.UserID)
+			if err != nil {
+				return nil, fmt.Errorf("load roles failed: user_id=%d, err=%w", identity.UserID, err)
+			}
+		}
+		return identity, nil
+	}
+	return nil, ErrNoCredentials
+}
+
+// Middleware 认证失败时返回 401
+func Middleware(roles RoleStore, authenticators ...Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, err := Authenticate(c.Request, roles, authenticators...)
+		if errors.Is(err, ErrNoCredentials) || errors.Is(err, ErrInvalidCredentials) {
+			apierr.Respond(c, apierr.Wrap(apierr.CodeUnauthorized, "unauthorized", err))
+			return
+		}
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("load roles failed", err))
+			return
+		}
+
+		c.Set(identityKey, identity)
+		c.Next()
+	}
+}
+
+// IdentityFrom 获取 Middleware 写入的身份，未认证时返回 nil
+func IdentityFrom(c *gin.Context) *Identity {
+	v, ok := c.Get(identityKey)
+	if !ok {
+		return nil
+	}
+	identity, _ := v.(*Identity)
+	return identity
+}
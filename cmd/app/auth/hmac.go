@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"task/cmd/app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HMAC 签名请求头
+const (
+	HeaderKeyID     = "X-Key-Id"         // 密钥 ID
+	HeaderTimestamp = "X-Timestamp"      // unix 秒
+	HeaderNonce     = "X-Nonce"          // 每个请求唯一的随机串
+	HeaderDigest    = "X-Content-SHA256" // 请求体 SHA-256，hex
+	HeaderSignature = "X-Signature"      // HMAC-SHA256(secret, StringToSign)，hex
+)
+
+// StringToSign 待签名字符串，各部分以换行分隔：
+//
+//	METHOD
+//	PATH?QUERY
+//	TIMESTAMP
+//	NONCE
+//	BODY_SHA256
+func StringToSign(method, uri, timestamp, nonce, digest string) string {
+	return method + "\n" + uri + "\n" + timestamp + "\n" + nonce + "\n" + digest
+}
+
+// Sign 计算签名，供调用方和测试使用
+func Sign(secret []byte, method, uri, timestamp, nonce string, body []byte) (digest, signature string) {
+	sum := sha256.Sum256(body)
+	digest = hex.EncodeToString(sum[:])
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(StringToSign(method, uri, timestamp, nonce, digest)))
+	return digest, hex.EncodeToString(mac.Sum(nil))
+}
+
+// NonceCache 记录窗口期内出现过的 nonce
+type NonceCache interface {
+	// Use 记录密钥 keyID 的 nonce，在 expiresAt 之前已经出现过时返回 false
+	Use(keyID, nonce string, expiresAt time.Time) (bool, error)
+}
+
+// MemoryNonceCache 进程内 nonce 缓存，过期的 nonce 会被定期清理
+// 只在本进程内有效，多实例部署或重启后无法防止重放，只用于测试；服务使用 DBNonceCache。
+type MemoryNonceCache struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryNonceCache() *MemoryNonceCache {
+	return &MemoryNonceCache{nonces: make(map[string]time.Time)}
+}
+
+func (c *MemoryNonceCache) Use(keyID, nonce string, expiresAt time.Time) (bool, error) {
+	key := keyID + ":" + nonce
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) > time.Minute {
+		for k, exp := range c.nonces {
+			if now.After(exp) {
+				delete(c.nonces, k)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if exp, ok := c.nonces[key]; ok && now.Before(exp) {
+		return false, nil
+	}
+	c.nonces[key] = expiresAt
+	return true, nil
+}
+
+// DBNonceCache 在 hmac_nonces 表中记录 nonce，所有实例共享，重启后仍然有效
+// (key_id, nonce) 唯一索引保证同一个 nonce 只有一个请求能记录成功；过期的 nonce 每分钟清理一次。
+type DBNonceCache struct {
+	db  *gorm.DB
+	now func() time.Time
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewDBNonceCache(db *gorm.DB) *DBNonceCache {
+	return &DBNonceCache{db: db, now: time.Now}
+}
+
+func (c *DBNonceCache) Use(keyID, nonce string, expiresAt time.Time) (bool, error) {
+	now := c.now()
+	c.sweep(now)
+
+	result := c.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.HMACNonce{KeyID: keyID, Nonce: nonce, ExpiresAt: expiresAt})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// 已有记录但已经过期、还没有被清理时重新记录，并发的请求只有一个能更新成功
+	result = c.db.Model(&model.HMACNonce{}).
+		Where("key_id = ? AND nonce = ? AND expires_at <= ?", keyID, nonce, now).
+		Update("expires_at", expiresAt)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// sweep 删除过期的 nonce，删除失败只影响表的大小，不影响校验
+func (c *DBNonceCache) sweep(now time.Time) {
+	c.mu.Lock()
+	if now.Sub(c.lastSweep) <= time.Minute {
+		c.mu.Unlock()
+		return
+	}
+	c.lastSweep = now
+	c.mu.Unlock()
+
+	err := c.db.Where("expires_at <= ?", now).Delete(&model.HMACNonce{}).Error
+	if err != nil {
+		slog.Warn("delete expired hmac nonces failed", "err", err)
+	}
+}
+
+// HMACKeyStore 按密钥 ID 查询未吊销的签名密钥，不存在时返回 gorm.ErrRecordNotFound
+type HMACKeyStore interface {
+	HMACKey(keyID string) (*model.HMACKey, error)
+}
+
+// DBHMACKeyStore 从 hmac_keys 表读取签名密钥
+type DBHMACKeyStore struct {
+	db *gorm.DB
+}
+
+func NewDBHMACKeyStore(db *gorm.DB) *DBHMACKeyStore {
+	return &DBHMACKeyStore{db: db}
+}
+
+func (s *DBHMACKeyStore) HMACKey(keyID string) (*model.HMACKey, error) {
+	var key model.HMACKey
+	err := s.db.Where("key_id = ?", keyID).Where("revoked_at IS NULL").First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// HMACAuthenticator 服务间调用的 HMAC-SHA256 签名认证
+// 时间戳超出 window 或 nonce 在 window 内重复的请求会被拒绝，截获的请求无法被重放；
+// 跨实例和重启防重放要求 nonces 由所有实例共享（DBNonceCache）。
+type HMACAuthenticator struct {
+	keys    HMACKeyStore
+	nonces  NonceCache
+	window  time.Duration
+	maxBody int64
+	now     func() time.Time
+}
+
+func NewHMACAuthenticator(keys HMACKeyStore, nonces NonceCache) *HMACAuthenticator {
+	return &HMACAuthenticator{
+		keys:    keys,
+		nonces:  nonces,
+		window:  time.Minute * 5,
+		maxBody: 1 << 20,
+		now:     time.Now,
+	}
+}
+
+func (a *HMACAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	keyID := r.Header.Get(HeaderKeyID)
+	if keyID == "" {
+		return nil, ErrNoCredentials
+	}
+	timestamp := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+	digest := r.Header.Get(HeaderDigest)
+	signature := r.Header.Get(HeaderSignature)
+	if timestamp == "" || nonce == "" || digest == "" || signature == "" {
+		return nil, fmt.Errorf("%w: missing signature headers", ErrInvalidCredentials)
+	}
+
+	// 时间窗口
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid timestamp", ErrInvalidCredentials)
+	}
+	now := a.now()
+	if d := now.Sub(time.Unix(ts, 0)); d > a.window || d < -a.window {
+		return nil, fmt.Errorf("%w: timestamp outside window", ErrInvalidCredentials)
+	}
+
+	key, err := a.keys.HMACKey(keyID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 读取请求体并还原，供后续 handler 使用
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, a.maxBody+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(body)) > a.maxBody {
+			return nil, fmt.Errorf("%w: body too large", ErrInvalidCredentials)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	wantDigest, wantSignature := Sign([]byte(key.Secret), r.Method, r.URL.RequestURI(), timestamp, nonce, body)
+	if !hmac.Equal([]byte(digest), []byte(wantDigest)) {
+		return nil, fmt.Errorf("%w: body digest mismatch", ErrInvalidCredentials)
+	}
+	if !hmac.Equal([]byte(signature), []byte(wantSignature)) {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidCredentials)
+	}
+
+	// 签名通过后再记录 nonce，避免伪造请求污染缓存
+	fresh, err := a.nonces.Use(keyID, nonce, now.Add(a.window*2))
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, fmt.Errorf("%w: replayed nonce", ErrInvalidCredentials)
+	}
+
+	return &Identity{
+		UserID: key.UserID,
+		Method: "hmac",
+	}, nil
+}
+
+// GenerateHMACKey 为用户生成签名密钥，返回密钥 ID 和密钥
+func GenerateHMACKey(db *gorm.DB, userID uint64, name string) (*model.HMACKey, error) {
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	key := &model.HMACKey{
+		KeyID:  "hk_" + hex.EncodeToString(id),
+		UserID: userID,
+		Name:   name,
+		Secret: hex.EncodeToString(secret),
+	}
+	err := db.Create(key).Error
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
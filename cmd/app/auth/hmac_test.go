@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"task/cmd/app/migrate"
+	"task/cmd/app/model"
+
+	"gorm.io/gorm"
+)
+
+type memoryHMACKeys map[string]*model.HMACKey
+
+func (m memoryHMACKeys) HMACKey(keyID string) (*model.HMACKey, error) {
+	key, ok := m[keyID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return key, nil
+}
+
+func signedRequest(secret, body, nonce string, ts time.Time) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/withdrawal/create?x=1", strings.NewReader(body))
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	digest, signature := Sign([]byte(secret), r.Method, r.URL.RequestURI(), timestamp, nonce, []byte(body))
+	r.Header.Set(HeaderKeyID, "hk_1")
+	r.Header.Set(HeaderTimestamp, timestamp)
+	r.Header.Set(HeaderNonce, nonce)
+	r.Header.Set(HeaderDigest, digest)
+	r.Header.Set(HeaderSignature, signature)
+	return r
+}
+
+func TestHMACAuthenticator(t *testing.T) {
+	keys := memoryHMACKeys{"hk_1": {KeyID: "hk_1", UserID: 9, Secret: "secret"}}
+	a := NewHMACAuthenticator(keys, NewMemoryNonceCache())
+	body := `{"amount": "1"}`
+
+	r := signedRequest("secret", body, "n1", time.Now())
+	identity, err := a.Authenticate(r)
+	if err != nil || identity.UserID != 9 || identity.Method != "hmac" {
+		t.Fatalf("identity=%+v, err=%v", identity, err)
+	}
+	// 请求体需要还原给 handler
+	if got, _ := io.ReadAll(r.Body); string(got) != body {
+		t.Fatalf("body=%s", got)
+	}
+
+	// 重放
+	if _, err := a.Authenticate(signedRequest("secret", body, "n1", time.Now())); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("replay: err=%v", err)
+	}
+
+	// 篡改请求体
+	r = signedRequest("secret", body, "n2", time.Now())
+	r.Body = io.NopCloser(strings.NewReader(`{"amount": "1000"}`))
+	if _, err := a.Authenticate(r); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("tampered body: err=%v", err)
+	}
+
+	// 时间戳过期
+	if _, err := a.Authenticate(signedRequest("secret", body, "n3", time.Now().Add(-time.Hour))); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("stale timestamp: err=%v", err)
+	}
+
+	// 密钥错误
+	if _, err := a.Authenticate(signedRequest("other", body, "n4", time.Now())); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong secret: err=%v", err)
+	}
+
+	// 没有签名头交给其他认证器
+	if _, err := a.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil)); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("no credentials: err=%v", err)
+	}
+}
+
+func openSQLite(t *testing.T) *gorm.DB {
+	db, err := model.Open(model.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.New(db)
+	if err == nil {
+		_, err = m.Up(context.Background())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// 多个实例共享数据库中的 nonce，一个实例接受的请求不能在另一个实例（或重启后）重放
+func TestDBNonceCache(t *testing.T) {
+	db := openSQLite(t)
+	keys := memoryHMACKeys{"hk_1": {KeyID: "hk_1", UserID: 9, Secret: "secret"}}
+	replica1 := NewHMACAuthenticator(keys, NewDBNonceCache(db))
+	replica2 := NewHMACAuthenticator(keys, NewDBNonceCache(db))
+	body := `{"amount": "1"}`
+
+	ts := time.Now()
+	if _, err := replica1.Authenticate(signedRequest("secret", body, "n1", ts)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replica2.Authenticate(signedRequest("secret", body, "n1", ts)); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("replay on another replica: err=%v", err)
+	}
+
+	// 不同密钥的相同 nonce 互不影响；过期的 nonce 被清理后可以重新使用
+	cache := NewDBNonceCache(db)
+	if ok, err := cache.Use("hk_2", "n1", ts.Add(time.Minute)); err != nil || !ok {
+		t.Fatalf("other key: ok=%v, err=%v", ok, err)
+	}
+	cache.now = func() time.Time { return ts.Add(time.Hour) }
+	if ok, err := cache.Use("hk_1", "n1", ts.Add(time.Hour*2)); err != nil || !ok {
+		t.Fatalf("expired nonce: ok=%v, err=%v", ok, err)
+	}
+	var count int64
+	if err := db.Model(&model.HMACNonce{}).Count(&count).Error; err != nil || count != 1 {
+		t.Fatalf("expired nonces not deleted: count=%d, err=%v", count, err)
+	}
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Claims JWT 中使用到的声明
+type Claims struct {
+	Subject   string   `json:"sub"` // 用户 ID
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+}
+
+// Audience aud 可以是字符串或字符串数组
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = Audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// JWTAuthenticator 通过 Authorization: Bearer <token> 认证，支持 HS256 和 RS256
+// 只接受构造时指定的算法，防止用公钥当 HMAC 密钥等算法混淆攻击。
+type JWTAuthenticator struct {
+	alg       string
+	secret    []byte
+	publicKey *rsa.PublicKey
+	issuer    string // 非空时校验 iss
+	audience  string // 非空时校验 aud
+	leeway    time.Duration
+	now       func() time.Time
+}
+
+// JWTOption JWT 认证配置项
+type JWTOption func(*JWTAuthenticator)
+
+// WithIssuer 要求 iss 等于 issuer
+func WithIssuer(issuer string) JWTOption {
+	return func(a *JWTAuthenticator) {
+		a.issuer = issuer
+	}
+}
+
+// WithAudience 要求 aud 包含 audience
+func WithAudience(audience string) JWTOption {
+	return func(a *JWTAuthenticator) {
+		a.audience = audience
+	}
+}
+
+func NewHS256Authenticator(secret []byte, opts ...JWTOption) *JWTAuthenticator {
+	return newJWTAuthenticator(&JWTAuthenticator{alg: "HS256", secret: secret}, opts)
+}
+
+func NewRS256Authenticator(publicKey *rsa.PublicKey, opts ...JWTOption) *JWTAuthenticator {
+	return newJWTAuthenticator(&JWTAuthenticator{alg: "RS256", publicKey: publicKey}, opts)
+}
+
+func newJWTAuthenticator(a *JWTAuthenticator, opts []JWTOption) *JWTAuthenticator {
+	a.leeway = time.Second * 30
+	a.now = time.Now
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	header := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return nil, ErrNoCredentials
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCredentials
+	}
+
+	// 算法不匹配时交给其他 JWT 认证器
+	var head struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &head); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if head.Alg != a.alg {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := a.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, fmt.Errorf("%w: invalid subject", ErrInvalidCredentials)
+	}
+
+	return &Identity{
+		UserID: userID,
+		Method: "jwt",
+	}, nil
+}
+
+// Verify 校验签名和有效期，返回声明
+func (a *JWTAuthenticator) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCredentials
+	}
+
+	var head struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &head); err != nil || head.Alg != a.alg {
+		return nil, fmt.Errorf("%w: unexpected alg", ErrInvalidCredentials)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if err := a.verifySignature(parts[0]+"."+parts[1], signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	now := a.now()
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(a.leeway)) {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidCredentials)
+	}
+	if claims.NotBefore != 0 && now.Add(a.leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, fmt.Errorf("%w: token not yet valid", ErrInvalidCredentials)
+	}
+	if a.issuer != "" && claims.Issuer != a.issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidCredentials)
+	}
+	if a.audience != "" && !contains(claims.Audience, a.audience) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidCredentials)
+	}
+
+	return &claims, nil
+}
+
+func (a *JWTAuthenticator) verifySignature(signingInput string, signature []byte) error {
+	switch a.alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, a.secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	case "RS256":
+		digest := sha256.Sum256([]byte(signingInput))
+		return rsa.VerifyPKCS1v15(a.publicKey, crypto.SHA256, digest[:], signature)
+	default:
+		return fmt.Errorf("unsupported alg: %s", a.alg)
+	}
+}
+
+// ParseRSAPublicKey 解析 PEM 格式的 RSA 公钥（PKIX 或 PKCS#1）
+func ParseRSAPublicKey(pemBytes []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("invalid pem")
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not a rsa public key")
+	}
+	return rsaKey, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestEngine(authenticators ...Authenticator) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware(nil, authenticators...))
+	r.GET("/whoami", func(c *gin.Context) {
+		c.String(http.StatusOK, strconv.FormatUint(IdentityFrom(c).UserID, 10))
+	})
+	return r
+}
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signHS256(t *testing.T, claims map[string]interface{}, secret []byte) string {
+	input := encodeSegment(t, map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, claims map[string]interface{}, key *rsa.PrivateKey) string {
+	input := encodeSegment(t, map[string]string{"alg": "RS256", "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func bearer(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func TestHS256Authenticator(t *testing.T) {
+	secret := []byte("secret")
+	a := NewHS256Authenticator(secret, WithAudience("task"))
+	exp := time.Now().Add(time.Hour).Unix()
+
+	identity, err := a.Authenticate(bearer(signHS256(t, map[string]interface{}{"sub": "7", "exp": exp, "aud": "task"}, secret)))
+	if err != nil || identity.UserID != 7 || identity.Method != "jwt" {
+		t.Fatalf("identity=%+v, err=%v", identity, err)
+	}
+
+	cases := map[string]string{
+		"wrong secret": signHS256(t, map[string]interface{}{"sub": "7", "exp": exp, "aud": "task"}, []byte("other")),
+		"expired":      signHS256(t, map[string]interface{}{"sub": "7", "exp": time.Now().Add(-time.Hour).Unix(), "aud": "task"}, secret),
+		"no exp":       signHS256(t, map[string]interface{}{"sub": "7", "aud": "task"}, secret),
+		"audience":     signHS256(t, map[string]interface{}{"sub": "7", "exp": exp, "aud": []string{"other"}}, secret),
+		"subject":      signHS256(t, map[string]interface{}{"sub": "alice", "exp": exp, "aud": "task"}, secret),
+	}
+	for name, token := range cases {
+		if _, err := a.Authenticate(bearer(token)); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: err=%v", name, err)
+		}
+	}
+}
+
+func TestRS256Authenticator(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewRS256Authenticator(&key.PublicKey)
+	claims := map[string]interface{}{"sub": "3", "exp": time.Now().Add(time.Hour).Unix()}
+
+	identity, err := a.Authenticate(bearer(signRS256(t, claims, key)))
+	if err != nil || identity.UserID != 3 {
+		t.Fatalf("identity=%+v, err=%v", identity, err)
+	}
+
+	// HS256 token 交给其他认证器
+	if _, err := a.Authenticate(bearer(signHS256(t, claims, []byte("secret")))); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	secret := []byte("secret")
+	token := signHS256(t, map[string]interface{}{"sub": "5", "exp": time.Now().Add(time.Hour).Unix()}, secret)
+	r := newTestEngine(NewHS256Authenticator(secret))
+
+	cases := []struct {
+		header string
+		code   int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer " + token, http.StatusOK},
+		{"Bearer " + token + "x", http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+		if c.header != "" {
+			req.Header.Set("Authorization", c.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != c.code {
+			t.Errorf("header=%q: code=%d, want %d", c.header, w.Code, c.code)
+		}
+		if w.Code == http.StatusOK && w.Body.String() != strconv.Itoa(5) {
+			t.Errorf("body=%s", w.Body.String())
+		}
+	}
+}
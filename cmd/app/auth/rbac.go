@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"task/cmd/app/apierr"
+	"task/cmd/app/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Role 角色
+type Role string
+
+const (
+	RoleRequester Role = "requester" // 发起提款申请
+	RoleApprover  Role = "approver"  // 审批提款申请
+	RoleExecutor  Role = "executor"  // 执行提款
+	RoleAuditor   Role = "auditor"   // 查看所有提款申请
+	RoleAdmin     Role = "admin"     // 全部权限
+)
+
+// Permission 权限，每个路由要求一个权限
+type Permission string
+
+const (
+	PermCreate          Permission = "create"
+	PermApprove         Permission = "approve"
+	PermExecute         Permission = "execute"
+	PermReadAll         Permission = "read-all"
+	PermManageUsers     Permission = "manage-users"
+	PermManageAddresses Permission = "manage-addresses"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleRequester: {PermCreate},
+	RoleApprover:  {PermApprove},
+	RoleExecutor:  {PermExecute},
+	RoleAuditor:   {PermReadAll},
+	RoleAdmin:     {PermCreate, PermApprove, PermExecute, PermReadAll, PermManageUsers, PermManageAddresses},
+}
+
+// ValidRole 是否是已定义的角色
+func ValidRole(role Role) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Can 身份的任一角色拥有该权限
+func (i *Identity) Can(perm Permission) bool {
+	for _, role := range i.Roles {
+		for _, p := range rolePermissions[role] {
+			if p == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RoleStore 查询用户的角色
+type RoleStore interface {
+	Roles(userID uint64) ([]Role, error)
+}
+
+// DBRoleStore 从 user_roles 表读取角色
+type DBRoleStore struct {
+	db *gorm.DB
+}
+
+func NewDBRoleStore(db *gorm.DB) *DBRoleStore {
+	return &DBRoleStore{db: db}
+}
+
+func (s *DBRoleStore) Roles(userID uint64) ([]Role, error) {
+	var userRoles []*model.UserRole
+	err := s.db.Where("user_id = ?", userID).Find(&userRoles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]Role, 0, len(userRoles))
+	for _, r := range userRoles {
+		roles = append(roles, Role(r.Role))
+	}
+	return roles, nil
+}
+
+// SetRoles 替换用户的角色
+func SetRoles(db *gorm.DB, userID uint64, roles []Role) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ?", userID).Delete(&model.UserRole{}).Error
+		if err != nil {
+			return err
+		}
+		for _, role := range roles {
+			err = tx.Create(&model.UserRole{UserID: userID, Role: string(role)}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Require 要求已认证身份拥有权限，否则返回 403
+func Require(perm Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity := IdentityFrom(c)
+		if identity == nil || !identity.Can(perm) {
+			apierr.Respond(c, apierr.New(apierr.CodeForbidden, "forbidden"))
+			return
+		}
+		c.Next()
+	}
+}
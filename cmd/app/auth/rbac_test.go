@@ -0,0 +1,28 @@
+package auth
+
+import "testing"
+
+func TestIdentityCan(t *testing.T) {
+	cases := []struct {
+		roles []Role
+		perm  Permission
+		want  bool
+	}{
+		{[]Role{RoleRequester}, PermCreate, true},
+		{[]Role{RoleRequester}, PermApprove, false},
+		{[]Role{RoleApprover}, PermApprove, true},
+		{[]Role{RoleApprover}, PermExecute, false},
+		{[]Role{RoleExecutor}, PermExecute, true},
+		{[]Role{RoleAuditor}, PermReadAll, true},
+		{[]Role{RoleAuditor}, PermManageUsers, false},
+		{[]Role{RoleRequester, RoleApprover}, PermApprove, true},
+		{[]Role{RoleAdmin}, PermManageUsers, true},
+		{nil, PermCreate, false},
+	}
+	for _, c := range cases {
+		identity := &Identity{Roles: c.roles}
+		if got := identity.Can(c.perm); got != c.want {
+			t.Errorf("roles=%v perm=%s: got %v, want %v", c.roles, c.perm, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"task/cmd/app/addressbook"
+	"task/cmd/app/auth"
+	"task/cmd/app/eth"
+	"task/cmd/app/ledger"
+	"task/cmd/app/logging"
+	"task/cmd/app/migrate"
+	"task/cmd/app/model"
+	"task/cmd/app/reconcile"
+
+	"gorm.io/gorm"
+)
+
+// runCommand 执行子命令，返回 false 表示不是子命令，按服务启动
+// 命令的结果输出到标准输出，供脚本读取；错误通过 slog 输出到标准错误。
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "reconcile":
+		runReconcile(args[1:])
+	case "apikey":
+		runAPIKey(args[1:])
+	case "hmackey":
+		runHMACKey(args[1:])
+	case "user":
+		runUser(args[1:])
+	case "blocklist":
+		runBlocklist(args[1:])
+	case "migrate":
+		runMigrate(args[1:])
+	case "ledger":
+		runLedger(args[1:])
+	default:
+		return false
+	}
+	return true
+}
+
+// runReconcile 对账并输出报告
+//
+//	server reconcile [-format json|csv] [-out file] [-from-block n] [-fix]
+func runReconcile(args []string) {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	format := fs.String("format", "json", "report format: json or csv")
+	out := fs.String("out", "", "write report to file instead of stdout")
+	fromBlock := fs.Uint64("from-block", 0, "first block to scan for sender transactions")
+	fix := fs.Bool("fix", false, "auto-fix safe discrepancies")
+	_ = fs.Parse(args)
+
+	if *format != "json" && *format != "csv" {
+		logging.Fatal("invalid format", "format", *format)
+	}
+
+	client := eth.Init()
+	db := loadConfig().openDB()
+
+	report, err := reconcile.New(db, client).Run(context.Background(), reconcile.Options{
+		FromBlock: *fromBlock,
+		AutoFix:   *fix,
+	})
+	if err != nil {
+		logging.Fatal("reconcile failed", "err", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			logging.Fatal("create report file failed", "file", *out, "err", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if *format == "csv" {
+		err = report.WriteCSV(w)
+	} else {
+		err = report.WriteJSON(w)
+	}
+	if err != nil {
+		logging.Fatal("write report failed", "err", err)
+	}
+}
+
+// runAPIKey 管理 API key
+//
+//	server apikey create -user id [-name name]
+//	server apikey revoke -id id
+func runAPIKey(args []string) {
+	if len(args) == 0 {
+		logging.Fatal("usage: apikey create|revoke")
+	}
+
+	fs := flag.NewFlagSet("apikey "+args[0], flag.ExitOnError)
+	userID := fs.Uint64("user", 0, "user id the key acts as")
+	name := fs.String("name", "", "key description")
+	id := fs.Uint("id", 0, "api key id")
+	_ = fs.Parse(args[1:])
+
+	db := loadConfig().openDB()
+
+	switch args[0] {
+	case "create":
+		if *userID == 0 {
+			logging.Fatal("-user is required")
+		}
+		key, apiKey, err := auth.GenerateAPIKey(db, *userID, *name)
+		if err != nil {
+			logging.Fatal("generate api key failed", "err", err)
+		}
+		// 明文只输出这一次
+		fmt.Printf("id=%d user_id=%d key=%s\n", apiKey.ID, apiKey.UserID, key)
+	case "revoke":
+		if *id == 0 {
+			logging.Fatal("-id is required")
+		}
+		err := auth.RevokeAPIKey(db, *id)
+		if err != nil {
+			logging.Fatal("revoke api key failed", "id", *id, "err", err)
+		}
+	default:
+		logging.Fatal("unknown apikey command", "command", args[0])
+	}
+}
+
+// runHMACKey 创建服务间调用的签名密钥
+//
+//	server hmackey create -user id [-name name]
+func runHMACKey(args []string) {
+	if len(args) == 0 || args[0] != "create" {
+		logging.Fatal("usage: hmackey create -user id [-name name]")
+	}
+
+	fs := flag.NewFlagSet("hmackey create", flag.ExitOnError)
+	userID := fs.Uint64("user", 0, "user id the key acts as")
+	name := fs.String("name", "", "key description")
+	_ = fs.Parse(args[1:])
+	if *userID == 0 {
+		logging.Fatal("-user is required")
+	}
+
+	db := loadConfig().openDB()
+	key, err := auth.GenerateHMACKey(db, *userID, *name)
+	if err != nil {
+		logging.Fatal("generate hmac key failed", "err", err)
+	}
+	fmt.Printf("key_id=%s user_id=%d secret=%s\n", key.KeyID, key.UserID, key.Secret)
+}
+
+// runBlocklist 从制裁名单文件导入黑名单，格式默认按扩展名判断
+//
+//	server blocklist import -file sanctions.csv [-format csv|json] [-source name]
+func runBlocklist(args []string) {
+	if len(args) == 0 || args[0] != "import" {
+		logging.Fatal("usage: blocklist import -file path [-format csv|json] [-source name]")
+	}
+
+	fs := flag.NewFlagSet("blocklist import", flag.ExitOnError)
+	file := fs.String("file", "", "sanctions file")
+	format := fs.String("format", "", "csv or json, defaults to file extension")
+	source := fs.String("source", "", "source recorded on each entry, defaults to file name")
+	_ = fs.Parse(args[1:])
+	if *file == "" {
+		logging.Fatal("-file is required")
+	}
+	if *format == "" {
+		*format = "csv"
+		if strings.EqualFold(filepath.Ext(*file), ".json") {
+			*format = "json"
+		}
+	}
+	if *source == "" {
+		*source = filepath.Base(*file)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		logging.Fatal("open file failed", "file", *file, "err", err)
+	}
+	defer f.Close()
+
+	entries, err := addressbook.ParseBlocklist(f, *format)
+	if err != nil {
+		logging.Fatal("parse blocklist failed", "err", err)
+	}
+
+	cfg := loadConfig()
+	added, err := addressbook.New(cfg.openDB(), cfg.AddressBook).Block(entries, *source, 0)
+	if err != nil {
+		logging.Fatal("import blocklist failed", "err", err)
+	}
+	fmt.Printf("total=%d added=%d\n", len(entries), added)
+}
+
+// runUser 管理用户，用于初始化第一个 admin
+//
+//	server user create -name name -roles admin,approver
+func runUser(args []string) {
+	if len(args) == 0 || args[0] != "create" {
+		logging.Fatal("usage: user create -name name -roles role[,role]")
+	}
+
+	fs := flag.NewFlagSet("user create", flag.ExitOnError)
+	name := fs.String("name", "", "user name")
+	rolesFlag := fs.String("roles", "", "comma separated roles")
+	_ = fs.Parse(args[1:])
+
+	var roles []auth.Role
+	for _, r := range strings.Split(*rolesFlag, ",") {
+		if r == "" {
+			continue
+		}
+		role := auth.Role(strings.TrimSpace(r))
+		if !auth.ValidRole(role) {
+			logging.Fatal("invalid role", "role", role)
+		}
+		roles = append(roles, role)
+	}
+	if *name == "" {
+		logging.Fatal("-name is required")
+	}
+
+	db := loadConfig().openDB()
+	user := &model.User{Name: *name}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return auth.SetRoles(tx, uint64(user.ID), roles)
+	})
+	if err != nil {
+		logging.Fatal("create user failed", "err", err)
+	}
+	fmt.Printf("user_id=%d\n", user.ID)
+}
+
+// runMigrate 执行表结构迁移
+//
+//	server migrate up
+//	server migrate down [-steps n]
+//	server migrate status
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		logging.Fatal("usage: migrate up|down|status")
+	}
+
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	steps := fs.Int("steps", 1, "number of migrations to roll back")
+	_ = fs.Parse(args[1:])
+
+	migrator, err := migrate.New(loadConfig().openDB())
+	if err != nil {
+		logging.Fatal("load migrations failed", "err", err)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		done, err := migrator.Up(ctx)
+		for _, m := range done {
+			fmt.Printf("up %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			logging.Fatal("migrate up failed", "err", err)
+		}
+		if len(done) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		if *steps <= 0 {
+			logging.Fatal("-steps must be positive")
+		}
+		done, err := migrator.Down(ctx, *steps)
+		for _, m := range done {
+			fmt.Printf("down %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			logging.Fatal("migrate down failed", "steps", *steps, "err", err)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			logging.Fatal("migrate status failed", "err", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Unknown {
+				state = "unknown"
+			} else if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		logging.Fatal("usage: migrate up|down|status")
+	}
+}
+
+// runLedger 账本维护
+//
+//	server ledger check
+func runLedger(args []string) {
+	if len(args) == 0 || args[0] != "check" {
+		logging.Fatal("usage: ledger check")
+	}
+
+	report, err := ledger.Check(loadConfig().openDB())
+	if report != nil {
+		fmt.Printf("entries=%d postings=%d total=%s unbalanced=%v\n",
+			report.Entries, report.Postings, report.Total, report.Unbalanced)
+	}
+	if err != nil {
+		logging.Fatal("ledger check failed", "err", err)
+	}
+}
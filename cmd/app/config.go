@@ -0,0 +1,255 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"task/cmd/app/addressbook"
+	"task/cmd/app/auth"
+	"task/cmd/app/expiry"
+	"task/cmd/app/limits"
+	"task/cmd/app/logging"
+	"task/cmd/app/model"
+	"task/cmd/app/tracing"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Config 服务配置，从环境变量读取
+type Config struct {
+	GRPCAddr    string // TASK_GRPC_ADDR，gRPC 监听地址，默认 :9090
+	MetricsAddr string // TASK_METRICS_ADDR，Prometheus /metrics 监听地址，默认 :9100，不经过认证，不应对外暴露
+
+	Log     logging.Config // TASK_LOG_LEVEL、TASK_LOG_FORMAT，日志级别和格式，默认 info、json
+	Tracing tracing.Config // TASK_OTLP_ENDPOINT、TASK_OTLP_SERVICE_NAME、TASK_TRACE_SAMPLE_RATIO，链路追踪，未设置 endpoint 时不导出
+
+	DBDriver string // TASK_DB_DRIVER，postgres 或 sqlite，默认 postgres
+	DBDSN    string // TASK_DB_DSN，默认为 docker compose 中的 postgres；sqlite 为文件路径，默认 task.db
+
+	JWTHS256Secret        string // TASK_JWT_HS256_SECRET，HS256 密钥，为空则不启用
+	JWTRS256PublicKeyFile string // TASK_JWT_RS256_PUBLIC_KEY_FILE，RS256 公钥 PEM 文件，为空则不启用
+	JWTIssuer             string // TASK_JWT_ISSUER，非空时校验 iss
+	JWTAudience           string // TASK_JWT_AUDIENCE，非空时校验 aud
+
+	Limits      limits.Config      // TASK_LIMIT_*，提款限额，未设置表示不限制
+	AddressBook addressbook.Config // TASK_ADDRESS_*，收款地址白名单策略
+	Expiry      expiry.Config      // TASK_APPROVAL_WINDOW、TASK_WITHDRAWAL_TTL，审批和提款申请的有效期，0 表示不过期
+
+	SendRetry    ExponentialBackoff // TASK_SEND_RETRY_INITIAL、TASK_SEND_RETRY_MAX、TASK_SEND_RETRY_MAX_RETRIES，上链失败的重试策略
+	ReceiptRetry MaxElapsedTime     // TASK_RECEIPT_RETRY_INITIAL、TASK_RECEIPT_RETRY_MAX、TASK_RECEIPT_RETRY_MAX_ELAPSED，查询 receipt 的重试策略
+
+	ReconcileLookback uint64 // TASK_RECONCILE_LOOKBACK，首次定时对账从最新区块往前扫描的区块数，默认 10000，之后从上次保存的区块继续
+}
+
+func loadConfig() *Config {
+	cfg := &Config{
+		GRPCAddr:    envString("TASK_GRPC_ADDR", ":9090"),
+		MetricsAddr: envString("TASK_METRICS_ADDR", ":9100"),
+
+		Log: logging.Config{
+			Level:  envLevel("TASK_LOG_LEVEL"),
+			Format: envString("TASK_LOG_FORMAT", logging.FormatJSON),
+		},
+		Tracing: tracing.Config{
+			Endpoint:    os.Getenv("TASK_OTLP_ENDPOINT"),
+			ServiceName: envString("TASK_OTLP_SERVICE_NAME", "task"),
+			SampleRatio: envRatio("TASK_TRACE_SAMPLE_RATIO", 1),
+		},
+
+		DBDriver: envString("TASK_DB_DRIVER", model.DriverPostgres),
+
+		JWTHS256Secret:        os.Getenv("TASK_JWT_HS256_SECRET"),
+		JWTRS256PublicKeyFile: os.Getenv("TASK_JWT_RS256_PUBLIC_KEY_FILE"),
+		JWTIssuer:             os.Getenv("TASK_JWT_ISSUER"),
+		JWTAudience:           os.Getenv("TASK_JWT_AUDIENCE"),
+
+		Limits: limits.Config{
+			MaxSingle:         envDecimal("TASK_LIMIT_MAX_SINGLE"),
+			PerRequester24h:   envDecimal("TASK_LIMIT_REQUESTER_24H"),
+			PerRequester7d:    envDecimal("TASK_LIMIT_REQUESTER_7D"),
+			PerDestination24h: envDecimal("TASK_LIMIT_DESTINATION_24H"),
+			PerDestination7d:  envDecimal("TASK_LIMIT_DESTINATION_7D"),
+			GlobalDaily:       envDecimal("TASK_LIMIT_GLOBAL_DAILY"),
+			MaxCountPerHour:   envInt("TASK_LIMIT_COUNT_PER_HOUR", 0),
+		},
+
+		AddressBook: addressbook.Config{
+			CoolingOff:       envDuration("TASK_ADDRESS_COOLING_OFF", time.Hour*24),
+			Policy:           addressbook.Policy(os.Getenv("TASK_ADDRESS_POLICY")),
+			DefaultApprovals: uint64(envInt("TASK_ADDRESS_DEFAULT_APPROVALS", 0)),
+			ExtraApprovals:   uint64(envInt("TASK_ADDRESS_EXTRA_APPROVALS", 0)),
+		},
+
+		Expiry: expiry.Config{
+			ApprovalWindow: envDuration("TASK_APPROVAL_WINDOW", time.Hour*24),
+			WithdrawalTTL:  envDuration("TASK_WITHDRAWAL_TTL", time.Hour*24*7),
+		},
+
+		ReconcileLookback: uint64(envInt("TASK_RECONCILE_LOOKBACK", 10000)),
+	}
+	cfg.SendRetry = envBackoff("TASK_SEND_RETRY", DefaultSendRetryPolicy())
+	receipt := DefaultReceiptRetryPolicy()
+	cfg.ReceiptRetry = MaxElapsedTime{
+		Policy:     envBackoff("TASK_RECEIPT_RETRY", receipt.Policy.(ExponentialBackoff)),
+		MaxElapsed: envDuration("TASK_RECEIPT_RETRY_MAX_ELAPSED", receipt.MaxElapsed),
+	}
+
+	cfg.DBDSN = os.Getenv("TASK_DB_DSN")
+	if cfg.DBDSN == "" && cfg.DBDriver == model.DriverSQLite {
+		cfg.DBDSN = "task.db"
+	}
+	if cfg.DBDSN == "" {
+		cfg.DBDSN = model.DefaultPostgresDSN
+	}
+	cfg.validate()
+	return cfg
+}
+
+func (cfg *Config) validate() {
+	switch cfg.DBDriver {
+	case model.DriverPostgres, model.DriverSQLite:
+	default:
+		logging.Fatal("invalid TASK_DB_DRIVER", "value", cfg.DBDriver)
+	}
+	switch cfg.AddressBook.Policy {
+	case "", addressbook.PolicyReject, addressbook.PolicyExtraApproval:
+	default:
+		logging.Fatal("invalid TASK_ADDRESS_POLICY", "value", cfg.AddressBook.Policy)
+	}
+	switch cfg.Log.Format {
+	case logging.FormatJSON, logging.FormatText:
+	default:
+		logging.Fatal("invalid TASK_LOG_FORMAT", "value", cfg.Log.Format)
+	}
+}
+
+// envString 读取字符串环境变量，未设置时为 def
+func envString(key, def string) string {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// envDuration 读取时长环境变量，如 24h，未设置时为 def
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		logging.Fatal("invalid "+key, "value", v)
+	}
+	return d
+}
+
+// envLevel 读取日志级别环境变量，未设置时为 info
+func envLevel(key string) slog.Level {
+	v := os.Getenv(key)
+	if v == "" {
+		return slog.LevelInfo
+	}
+	level, err := logging.ParseLevel(v)
+	if err != nil {
+		logging.Fatal("invalid "+key, "value", v)
+	}
+	return level
+}
+
+// envRatio 读取 0 到 1 之间的比例，未设置时为 def
+func envRatio(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || f > 1 {
+		logging.Fatal("invalid "+key, "value", v)
+	}
+	return f
+}
+
+// envBackoff 读取 <prefix>_INITIAL、<prefix>_MAX、<prefix>_MAX_RETRIES，覆盖 def 中对应的字段
+func envBackoff(prefix string, def ExponentialBackoff) ExponentialBackoff {
+	def.Initial = envDuration(prefix+"_INITIAL", def.Initial)
+	def.Max = envDuration(prefix+"_MAX", def.Max)
+	def.MaxRetries = int(envInt(prefix+"_MAX_RETRIES", int64(def.MaxRetries)))
+	if def.Initial <= 0 {
+		logging.Fatal("invalid "+prefix+"_INITIAL", "value", def.Initial)
+	}
+	return def
+}
+
+// envDecimal 读取十进制数环境变量，未设置时为 0
+func envDecimal(key string) decimal.Decimal {
+	v := os.Getenv(key)
+	if v == "" {
+		return decimal.Zero
+	}
+	d, err := decimal.NewFromString(v)
+	if err != nil || d.IsNegative() {
+		logging.Fatal("invalid "+key, "value", v)
+	}
+	return d
+}
+
+// envInt 读取非负整数环境变量，未设置时为 def
+func envInt(key string, def int64) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		logging.Fatal("invalid "+key, "value", v)
+	}
+	return n
+}
+
+// openDB 连接数据库，并为每条 SQL 创建 span
+func (cfg *Config) openDB() *gorm.DB {
+	db := model.Init(cfg.DBDriver, cfg.DBDSN)
+	err := db.Use(tracing.GormPlugin{})
+	if err != nil {
+		logging.Fatal("register gorm tracing failed", "err", err)
+	}
+	return db
+}
+
+// authenticators 按配置创建认证器，API key 和 HMAC 签名总是启用
+func (cfg *Config) authenticators(db *gorm.DB) []auth.Authenticator {
+	authenticators := []auth.Authenticator{
+		auth.NewAPIKeyAuthenticator(db),
+		auth.NewHMACAuthenticator(auth.NewDBHMACKeyStore(db), auth.NewDBNonceCache(db)),
+	}
+
+	var opts []auth.JWTOption
+	if cfg.JWTIssuer != "" {
+		opts = append(opts, auth.WithIssuer(cfg.JWTIssuer))
+	}
+	if cfg.JWTAudience != "" {
+		opts = append(opts, auth.WithAudience(cfg.JWTAudience))
+	}
+
+	if cfg.JWTHS256Secret != "" {
+		authenticators = append(authenticators, auth.NewHS256Authenticator([]byte(cfg.JWTHS256Secret), opts...))
+	}
+	if cfg.JWTRS256PublicKeyFile != "" {
+		pemBytes, err := os.ReadFile(cfg.JWTRS256PublicKeyFile)
+		if err != nil {
+			logging.Fatal("read jwt public key failed", "file", cfg.JWTRS256PublicKeyFile, "err", err)
+		}
+		publicKey, err := auth.ParseRSAPublicKey(pemBytes)
+		if err != nil {
+			logging.Fatal("parse jwt public key failed", "file", cfg.JWTRS256PublicKeyFile, "err", err)
+		}
+		authenticators = append(authenticators, auth.NewRS256Authenticator(publicKey, opts...))
+	}
+
+	return authenticators
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+
+	"task/cmd/app/addressbook"
+	"task/cmd/app/apierr"
+	"task/cmd/app/limits"
+	"task/cmd/app/repository"
+)
+
+// destinationError 收款地址被拒绝时返回 DESTINATION_REJECTED，其他错误按 updateError 处理
+func destinationError(err error) error {
+	if rejected, ok := addressbook.IsRejected(err); ok {
+		return apierr.Wrap(apierr.CodeDestinationRejected, "destination rejected", err).
+			WithMeta("address", rejected.Address).
+			WithMeta("reason", rejected.Reason)
+	}
+	return updateError("check destination failed", err)
+}
+
+// limitError 超出限额时返回 LIMIT_EXCEEDED，附带触发的限额和剩余额度，其他错误返回 INTERNAL_ERROR
+func limitError(err error) error {
+	var exceeded *limits.ExceededError
+	if errors.As(err, &exceeded) {
+		return apierr.Wrap(apierr.CodeLimitExceeded, "limit exceeded", err).
+			WithMeta("limit", exceeded)
+	}
+	return apierr.Internal("check limits failed", err)
+}
+
+// updateError 提款申请已被其他请求修改时返回可重试的 CONCURRENT_UPDATE，其他错误返回 INTERNAL_ERROR
+func updateError(message string, err error) error {
+	if errors.Is(err, repository.ErrConflict) {
+		return apierr.ConcurrentUpdate(err)
+	}
+	return apierr.Internal(message, err)
+}
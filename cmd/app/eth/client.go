@@ -1,12 +1,15 @@
 package eth
 
 import (
+	"context"
 	"encoding/hex"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"math/big"
+	"strings"
 	"time"
 
-	"github.com/shopspring/decimal"
 	"github.com/umbracle/ethgo"
 	"github.com/umbracle/ethgo/jsonrpc"
 	"github.com/umbracle/ethgo/wallet"
@@ -24,18 +27,59 @@ type Client struct {
 	*jsonrpc.Eth
 }
 
-func (c *Client) SendTransaction(amount decimal.Decimal) (ethgo.Hash, error) {
+// SignedTransaction 已签名、未广播的交易
+type SignedTransaction struct {
+	Hash     ethgo.Hash // 交易哈希
+	Raw      []byte     // RLP 编码后的签名交易
+	Nonce    uint64
+	From     string // 发送地址
+	GasLimit uint64 // gas limit
+	GasPrice uint64 // gas price，单位 wei
+}
+
+// SendTransaction 签名并广播交易，value 单位 wei
+func (c *Client) SendTransaction(ctx context.Context, to string, value *big.Int) (ethgo.Hash, error) {
+	signed, err := c.SignTransaction(ctx, to, value)
+	if err != nil {
+		return ethgo.Hash{}, err
+	}
+
+	// 发送交易
+	hash, err := c.SendRawTransactionContext(ctx, signed.Raw)
+	if err != nil {
+		slog.ErrorContext(ctx, "send raw transaction failed", "tx_hash", signed.Hash.String(), "nonce", signed.Nonce, "err", err)
+		return ethgo.Hash{}, err
+	}
+	slog.InfoContext(ctx, "transaction sent", "tx_hash", hash.String(), "nonce", signed.Nonce)
+
+	return hash, nil
+}
+
+// SignTransaction 构造并签名交易，不广播
+// 签名后的交易哈希是确定的，可以先落库再广播，广播失败或进程崩溃后重新广播同一笔交易。
+// value 单位 wei，由调用方按资产精度转换，见 assets 包。
+// ctx 用于日志关联（如请求 ID、withdrawal_id），每次 JSON-RPC 调用都是 ctx 中 span 的子 span。
+func (c *Client) SignTransaction(ctx context.Context, to string, value *big.Int) (*SignedTransaction, error) {
+	if value == nil || value.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid value: %v", value)
+	}
+
 	// 获取 gas
-	gasPrice, err := c.GasPrice()
+	gasPrice, err := c.gasPrice(ctx)
 	if err != nil {
-		log.Fatalf("get gas price failed: err=%v", err)
+		slog.ErrorContext(ctx, "get gas price failed", "err", err)
+		return nil, err
 	}
-	log.Printf("gasPrice=%d", gasPrice)
+	slog.DebugContext(ctx, "gas price", "gas_price", gasPrice)
 
 	fromAddr := convertAddress(From)
-	toAddr := convertAddress(To)
+	toAddr, err := ParseAddress(to)
+	if err != nil {
+		slog.WarnContext(ctx, "invalid to address", "to", to, "err", err)
+		return nil, err
+	}
 
-	gas, err := c.EstimateGas(&ethgo.CallMsg{
+	gas, err := c.estimateGas(ctx, &ethgo.CallMsg{
 		From:     fromAddr,
 		To:       &toAddr,
 		Data:     nil,
@@ -44,36 +88,35 @@ func (c *Client) SendTransaction(amount decimal.Decimal) (ethgo.Hash, error) {
 		Value:    new(big.Int).SetUint64(1),
 	})
 	if err != nil {
-		log.Printf("estimate gas failed: err=%v", err)
-		return ethgo.Hash{}, err
+		slog.ErrorContext(ctx, "estimate gas failed", "err", err)
+		return nil, err
 	}
-	log.Printf("gas=%d", gas)
+	slog.DebugContext(ctx, "gas estimated", "gas", gas)
 
 	// 获取 nonce
-	nonce, err := c.GetNonce(fromAddr, ethgo.Latest)
+	nonce, err := c.getNonce(ctx, fromAddr)
 	if err != nil {
-		log.Printf("get nonce failed: err=%v", err)
-		return ethgo.Hash{}, err
+		slog.ErrorContext(ctx, "get nonce failed", "err", err)
+		return nil, err
 	}
-	log.Printf("nonce=%d", nonce)
+	slog.DebugContext(ctx, "nonce", "nonce", nonce)
 
 	// 获取 chainID
-	chainID, err := c.ChainID()
+	chainID, err := c.chainID(ctx)
 	if err != nil {
-		log.Printf("get chain id failed: err=%v", err)
-		return ethgo.Hash{}, err
+		slog.ErrorContext(ctx, "get chain id failed", "nonce", nonce, "err", err)
+		return nil, err
 	}
-	log.Printf("chainID=%d", chainID)
+	slog.DebugContext(ctx, "chain id", "chain_id", chainID)
 
 	// 构造交易
-	wei := ethToWei(amount)
 	txn := &ethgo.Transaction{
 		Type:     ethgo.TransactionLegacy,
 		From:     fromAddr,
 		To:       &toAddr,
 		GasPrice: gasPrice,
 		Gas:      gas + 10,
-		Value:    wei,
+		Value:    value,
 		Nonce:    nonce,
 		ChainID:  chainID,
 	}
@@ -81,57 +124,58 @@ func (c *Client) SendTransaction(amount decimal.Decimal) (ethgo.Hash, error) {
 	// 构造签名
 	key, err := wallet.NewWalletFromPrivKey(convertPrivateKey(FromPrivateKeys))
 	if err != nil {
-		log.Printf("new wallet from private key failed: err=%v", err)
-		return ethgo.Hash{}, err
+		slog.ErrorContext(ctx, "new wallet from private key failed", "err", err)
+		return nil, err
 	}
 
 	// 签名
 	signer := wallet.NewEIP155Signer(chainID.Uint64())
 	signedTxn, err := signer.SignTx(txn, key)
 	if err != nil {
-		log.Printf("sign transaction failed: err=%v", err)
-		return ethgo.Hash{}, err
+		slog.ErrorContext(ctx, "sign transaction failed", "nonce", nonce, "err", err)
+		return nil, err
 	}
 
 	// 编码
 	txnRaw, err := signedTxn.MarshalRLPTo(nil)
 	if err != nil {
-		log.Printf("marshal rlp failed: err=%v", err)
-		return ethgo.Hash{}, err
+		slog.ErrorContext(ctx, "marshal rlp failed", "nonce", nonce, "err", err)
+		return nil, err
 	}
 
-	// 发送交易
-	hash, err := c.SendRawTransaction(txnRaw)
+	hash, err := signedTxn.GetHash()
 	if err != nil {
-		log.Printf("send raw transaction failed: err=%v", err)
-		return ethgo.Hash{}, err
+		slog.ErrorContext(ctx, "get transaction hash failed", "nonce", nonce, "err", err)
+		return nil, err
 	}
-	log.Printf("hash=%s", hash.String())
+	slog.InfoContext(ctx, "transaction signed", "tx_hash", hash.String(), "nonce", nonce,
+		"gas_limit", txn.Gas, "gas_price", txn.GasPrice)
 
-	return hash, nil
+	return &SignedTransaction{
+		Hash:     hash,
+		Raw:      txnRaw,
+		Nonce:    nonce,
+		From:     From,
+		GasLimit: txn.Gas,
+		GasPrice: txn.GasPrice,
+	}, nil
 }
 
 func (c *Client) PrintBalance() {
 	// 获取地址
 	accounts, err := c.Accounts()
 	if err != nil {
-		log.Fatalf("get accounts failed: err=%v", err)
+		slog.Error("get accounts failed", "err", err)
+		return
 	}
 
-	log.Printf("accounts=%+v", accounts)
-
 	for _, account := range accounts {
 		// 获取余额
 		balance, err := c.GetBalance(account, ethgo.Latest)
 		if err != nil {
 			return
 		}
-		log.Println()
-		log.Println()
-		log.Printf("account=%s ", account.String())
-		printfBalance(balance)
-		log.Println()
-		log.Println()
+		printfBalance(account, balance)
 	}
 }
 
@@ -143,15 +187,15 @@ func Init() *Client {
 		client, err := jsonrpc.NewClient("http://task-ganache:8545")
 		// client, err := jsonrpc.NewClient("http://localhost:8545")
 		if err != nil {
-			log.Printf("new client err: %v", err)
+			slog.Warn("new eth client failed", "err", err)
 			continue
 		}
 		version, err := client.Web3().ClientVersion()
 		if err != nil {
-			log.Printf("get client version err: %v", err)
+			slog.Warn("get eth client version failed", "err", err)
 			continue
 		}
-		log.Println(version)
+		slog.Info("eth client connected", "version", version)
 		return &Client{client.Eth()}
 	}
 
@@ -176,6 +220,31 @@ func convertAddress(addressHex string) ethgo.Address {
 	return address
 }
 
+// ErrInvalidAddress 地址格式错误
+var ErrInvalidAddress = errors.New("invalid address")
+
+// ParseAddress 解析 0x 开头的 20 字节十六进制地址
+// 大小写混合的地址按 EIP-55 校验 checksum；全小写或全大写的地址不带 checksum，不校验。
+func ParseAddress(addressHex string) (ethgo.Address, error) {
+	if len(addressHex) != 42 || !strings.HasPrefix(addressHex, "0x") {
+		return ethgo.Address{}, fmt.Errorf("%w: %s", ErrInvalidAddress, addressHex)
+	}
+	addressBytes, err := hex.DecodeString(addressHex[2:])
+	if err != nil {
+		return ethgo.Address{}, fmt.Errorf("%w: %s", ErrInvalidAddress, addressHex)
+	}
+
+	var address ethgo.Address
+	copy(address[:], addressBytes)
+
+	digits := addressHex[2:]
+	mixedCase := digits != strings.ToLower(digits) && digits != strings.ToUpper(digits)
+	if mixedCase && addressHex != address.String() {
+		return ethgo.Address{}, fmt.Errorf("%w: checksum mismatch: %s", ErrInvalidAddress, addressHex)
+	}
+	return address, nil
+}
+
 // convertPrivateKey 转换私钥
 func convertPrivateKey(privateKeyHex string) []byte {
 	// 去除前缀0x
@@ -191,20 +260,19 @@ func convertPrivateKey(privateKeyHex string) []byte {
 }
 
 // printfBalance 打印余额
-func printfBalance(balance *big.Int) {
+func printfBalance(account ethgo.Address, balance *big.Int) {
 	// 以太坊中 1 ether = 1e18 wei
 	ether := new(big.Float).Quo(new(big.Float).SetInt(balance), big.NewFloat(1e18))
 
-	log.Printf("Balance in Wei: %s", balance) // 余额以wei表示
-	log.Printf("Balance in Ether: %f", ether) // 余额以ether表示
+	slog.Info("balance", "account", account.String(), "wei", balance.String(), "ether", ether.Text('f', 18))
 }
 
-// EthToWei 将 Eth 转换为 Wei
-func ethToWei(amountInEth decimal.Decimal) *big.Int {
-	weiMultiplier := decimal.NewFromInt(1e18) // 1 ether = 1e18 wei
-	amountInWei := amountInEth.Mul(weiMultiplier)
-
-	// 将 decimal.Decimal 转换为 *big.Int
-	weiBigInt, _ := new(big.Int).SetString(amountInWei.String(), 10)
-	return weiBigInt
+// DecodeGas 从签名交易中解析 gas limit 和 gas price
+func DecodeGas(raw []byte) (gasLimit, gasPrice uint64, err error) {
+	txn := &ethgo.Transaction{}
+	err = txn.UnmarshalRLP(raw)
+	if err != nil {
+		return 0, 0, err
+	}
+	return txn.Gas, txn.GasPrice, nil
 }
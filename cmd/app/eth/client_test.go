@@ -0,0 +1,43 @@
+package eth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	// EIP-55 中的示例地址
+	valid := []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	}
+	for _, addr := range valid {
+		parsed, err := ParseAddress(addr)
+		if err != nil || parsed.String() != addr {
+			t.Errorf("%s: parsed=%s, err=%v", addr, parsed, err)
+		}
+		// 不带 checksum 的全小写、全大写地址同样接受
+		if _, err := ParseAddress(strings.ToLower(addr)); err != nil {
+			t.Errorf("%s: lowercase rejected: %v", addr, err)
+		}
+		if _, err := ParseAddress("0x" + strings.ToUpper(addr[2:])); err != nil {
+			t.Errorf("%s: uppercase rejected: %v", addr, err)
+		}
+	}
+
+	invalid := []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", // checksum 错误
+		"0x5AAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", // checksum 错误
+		"5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed00",
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeA",
+		"0xzaAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+	}
+	for _, addr := range invalid {
+		if _, err := ParseAddress(addr); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("%s: expected ErrInvalidAddress, got %v", addr, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package eth
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"task/cmd/app/metrics"
+	"task/cmd/app/tracing"
+
+	"github.com/umbracle/ethgo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 以下方法覆盖 jsonrpc.Eth 的同名方法，记录调用耗时、失败次数和 span
+// 带 Context 后缀的方法把 span 挂在 ctx 中的父 span 下，不带后缀的方法使用 context.Background()。
+
+// call 执行一次 JSON-RPC 调用
+func call[T any](ctx context.Context, method string, fn func() (T, error)) (T, error) {
+	_, span := tracing.Start(ctx, "eth."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "jsonrpc"),
+			attribute.String("rpc.method", method),
+		),
+	)
+	start := time.Now()
+	v, err := fn()
+	metrics.ObserveRPC(method, start, &err)
+	tracing.End(span, err)
+	return v, err
+}
+
+// GasPrice 查询当前 gas price
+func (c *Client) GasPrice() (uint64, error) {
+	return c.gasPrice(context.Background())
+}
+
+func (c *Client) gasPrice(ctx context.Context) (uint64, error) {
+	return call(ctx, "GasPrice", c.Eth.GasPrice)
+}
+
+// EstimateGas 估算交易需要的 gas
+func (c *Client) EstimateGas(msg *ethgo.CallMsg) (uint64, error) {
+	return c.estimateGas(context.Background(), msg)
+}
+
+func (c *Client) estimateGas(ctx context.Context, msg *ethgo.CallMsg) (uint64, error) {
+	return call(ctx, "EstimateGas", func() (uint64, error) {
+		return c.Eth.EstimateGas(msg)
+	})
+}
+
+// getNonce 查询地址最新的 nonce
+func (c *Client) getNonce(ctx context.Context, addr ethgo.Address) (uint64, error) {
+	return call(ctx, "GetNonce", func() (uint64, error) {
+		return c.Eth.GetNonce(addr, ethgo.Latest)
+	})
+}
+
+// chainID 查询链 ID
+func (c *Client) chainID(ctx context.Context) (*big.Int, error) {
+	return call(ctx, "ChainID", c.Eth.ChainID)
+}
+
+// SendRawTransaction 广播签名交易
+func (c *Client) SendRawTransaction(data []byte) (ethgo.Hash, error) {
+	return c.SendRawTransactionContext(context.Background(), data)
+}
+
+// SendRawTransactionContext 广播签名交易，span 挂在 ctx 下
+func (c *Client) SendRawTransactionContext(ctx context.Context, data []byte) (ethgo.Hash, error) {
+	return call(ctx, "SendRawTransaction", func() (ethgo.Hash, error) {
+		return c.Eth.SendRawTransaction(data)
+	})
+}
+
+// GetTransactionReceipt 查询 receipt，交易未上链时返回 nil
+func (c *Client) GetTransactionReceipt(hash ethgo.Hash) (*ethgo.Receipt, error) {
+	return c.GetTransactionReceiptContext(context.Background(), hash)
+}
+
+// GetTransactionReceiptContext 查询 receipt，span 挂在 ctx 下
+func (c *Client) GetTransactionReceiptContext(ctx context.Context, hash ethgo.Hash) (*ethgo.Receipt, error) {
+	return call(ctx, "GetTransactionReceipt", func() (*ethgo.Receipt, error) {
+		return c.Eth.GetTransactionReceipt(hash)
+	})
+}
+
+// HotWalletBalance 热钱包（发送地址）的余额，单位 wei
+func (c *Client) HotWalletBalance() (*big.Int, error) {
+	return call(context.Background(), "GetBalance", func() (*big.Int, error) {
+		return c.Eth.GetBalance(convertAddress(From), ethgo.Latest)
+	})
+}
@@ -0,0 +1,82 @@
+package events
+
+import (
+	"encoding/json"
+
+	"task/cmd/app/model"
+
+	"gorm.io/gorm"
+)
+
+// Type 事件类型
+type Type string
+
+const (
+	TypeCreated         Type = "withdrawal.created"          // 创建提款申请
+	TypeApproved        Type = "withdrawal.approved"         // 审批
+	TypeApprovalRevoked Type = "withdrawal.approval_revoked" // 撤销审批
+	TypeStateChanged    Type = "withdrawal.state_changed"    // 状态或交易哈希变化
+	TypeReceiptPending  Type = "withdrawal.receipt_pending"  // 查询 receipt 时交易尚未上链
+)
+
+// Types 所有事件类型，用于校验订阅的事件过滤
+var Types = []Type{TypeCreated, TypeApproved, TypeApprovalRevoked, TypeStateChanged, TypeReceiptPending}
+
+// Valid 是否为已知的事件类型
+func (t Type) Valid() bool {
+	for _, typ := range Types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Sink 事件的下游，在写入事件的同一个事务中调用
+// 返回错误时事件和下游的写入随调用方事务一起回滚。
+type Sink interface {
+	Handle(tx *gorm.DB, event *model.WithdrawalEvent, withdrawal *model.Withdrawal) error
+}
+
+// Recorder 把事件写入 withdrawal_events，并交给各个 Sink
+// nil Recorder 不记录任何事件。
+type Recorder struct {
+	sinks []Sink
+}
+
+func NewRecorder(sinks ...Sink) *Recorder {
+	return &Recorder{sinks: sinks}
+}
+
+// Record 在 tx 中记录 withdrawal 的一个事件，data 为附加信息，可以为 nil
+func (r *Recorder) Record(tx *gorm.DB, withdrawal *model.Withdrawal, typ Type, data map[string]interface{}) (*model.WithdrawalEvent, error) {
+	if r == nil {
+		return nil, nil
+	}
+
+	event := &model.WithdrawalEvent{
+		WithdrawalID: uint64(withdrawal.ID),
+		Type:         string(typ),
+		Status:       withdrawal.Status,
+		TxHash:       withdrawal.TxHash,
+	}
+	if len(data) > 0 {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		event.Data = string(b)
+	}
+
+	err := tx.Create(event).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, sink := range r.sinks {
+		err = sink.Handle(tx, event, withdrawal)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return event, nil
+}
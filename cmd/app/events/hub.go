@@ -0,0 +1,184 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"task/cmd/app/model"
+
+	"gorm.io/gorm"
+)
+
+// sequenceLockKey postgres advisory lock 的 key，多个实例依次分配 seq
+const sequenceLockKey = 72_657_004
+
+// sequenceBatch 每次最多分配 seq 的事件数
+const sequenceBatch = 500
+
+// Sequence 为已提交、尚未分配 seq 的事件按 ID 顺序分配递增的 seq，返回分配的数量
+// 事件 ID 在插入时分配，长事务中的事件可能晚于 ID 更大的事件提交；seq 在提交之后分配，
+// 只读取已提交的行，并且在锁内按最大 seq 递增，读取方按 seq 递增读取不会漏掉较晚提交的事件。
+func Sequence(db *gorm.DB) (int, error) {
+	// 没有待分配的事件时不开启写事务
+	var pending int64
+	err := db.Model(&model.WithdrawalEvent{}).Where("seq = 0").Limit(1).Count(&pending).Error
+	if err != nil || pending == 0 {
+		return 0, err
+	}
+
+	var ids []uint64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == model.DriverPostgres {
+			err := tx.Exec("SELECT pg_advisory_xact_lock(?)", sequenceLockKey).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		var seq uint64
+		err := tx.Model(&model.WithdrawalEvent{}).Select("COALESCE(MAX(seq), 0)").Scan(&seq).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&model.WithdrawalEvent{}).Where("seq = 0").Order("id").Limit(sequenceBatch).Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			seq++
+			err = tx.Model(&model.WithdrawalEvent{}).Where("id = ?", id).Update("seq", seq).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// Hub 把已提交的事件推送给本进程内的订阅者（SSE、gRPC 连接）
+// 事件与状态变化在同一个事务中写入事件表，Hub 定期为已提交的事件分配 seq，再按 seq 读取并推送，
+// 订阅者不会看到随后回滚的事务中的事件，其他实例产生的事件同样会被推送；
+// 订阅者消费过慢时关闭其 channel，由客户端带 Last-Event-ID 重连后从事件表补齐。
+type Hub struct {
+	db       *gorm.DB
+	interval time.Duration // 读取事件表的间隔
+
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	buffer  int
+	lastSeq uint64 // 已推送的最大 seq
+	started bool
+}
+
+// Subscription 订阅，WithdrawalID 为 0 表示所有提款申请
+type Subscription struct {
+	C            <-chan *model.WithdrawalEvent
+	ch           chan *model.WithdrawalEvent
+	withdrawalID uint64
+}
+
+func NewHub(db *gorm.DB) *Hub {
+	return &Hub{
+		db:       db,
+		interval: time.Millisecond * 500,
+		subs:     make(map[*Subscription]struct{}),
+		buffer:   64,
+	}
+}
+
+// Subscribe 订阅 withdrawalID 的事件，用完后需要调用 Unsubscribe
+func (h *Hub) Subscribe(withdrawalID uint64) *Subscription {
+	ch := make(chan *model.WithdrawalEvent, h.buffer)
+	sub := &Subscription{C: ch, ch: ch, withdrawalID: withdrawalID}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+// Unsubscribe 取消订阅，可以重复调用
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Run 定期分配 seq 并推送新事件，直到 ctx 取消
+func (h *Hub) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := h.poll(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "poll withdrawal events failed", "err", err)
+		}
+	}
+}
+
+// poll 分配 seq，推送上次之后的事件
+// 启动时从当前最大的 seq 开始，之前的事件由订阅者自己从事件表补发。
+func (h *Hub) poll(ctx context.Context) error {
+	db := h.db.WithContext(ctx)
+	_, err := Sequence(db)
+	if err != nil {
+		return err
+	}
+
+	if !h.started {
+		err = db.Model(&model.WithdrawalEvent{}).Select("COALESCE(MAX(seq), 0)").Scan(&h.lastSeq).Error
+		if err != nil {
+			return err
+		}
+		h.started = true
+		return nil
+	}
+
+	for {
+		var logged []*model.WithdrawalEvent
+		err = db.Where("seq > ?", h.lastSeq).Order("seq").Limit(sequenceBatch).Find(&logged).Error
+		if err != nil {
+			return err
+		}
+		for _, event := range logged {
+			h.publish(event)
+			h.lastSeq = event.Seq
+		}
+		if len(logged) < sequenceBatch {
+			return nil
+		}
+	}
+}
+
+// publish 推送事件，不会阻塞
+func (h *Hub) publish(event *model.WithdrawalEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if sub.withdrawalID != 0 && sub.withdrawalID != event.WithdrawalID {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			delete(h.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package events
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"task/cmd/app/migrate"
+	"task/cmd/app/model"
+
+	"gorm.io/gorm"
+)
+
+func openSQLite(t *testing.T) *gorm.DB {
+	db, err := model.Open(model.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.New(db)
+	if err == nil {
+		_, err = m.Up(context.Background())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// receive 取出 sub 中已有的事件 ID
+func receive(sub *Subscription) []uint {
+	var ids []uint
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return append(ids, 0)
+			}
+			ids = append(ids, e.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestHub(t *testing.T) {
+	db := openSQLite(t)
+	ctx := context.Background()
+	hub := NewHub(db)
+	hub.buffer = 2
+	if err := hub.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	one := hub.Subscribe(1)
+	all := hub.Subscribe(0)
+	defer hub.Unsubscribe(one)
+	defer hub.Unsubscribe(all)
+
+	// 未提交和回滚的事件不推送
+	tx := db.Begin()
+	if err := tx.Create(&model.WithdrawalEvent{ID: 10, WithdrawalID: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := hub.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ids := receive(all); len(ids) != 0 {
+		t.Fatalf("uncommitted events published: %v", ids)
+	}
+	tx.Rollback()
+
+	// ID 较小的事件较晚提交，仍然按提交顺序推送
+	if err := db.Create(&model.WithdrawalEvent{ID: 12, WithdrawalID: 2}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := hub.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.WithdrawalEvent{ID: 11, WithdrawalID: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := hub.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ids := receive(all); len(ids) != 2 || ids[0] != 12 || ids[1] != 11 {
+		t.Fatalf("unexpected events: %v", ids)
+	}
+	if ids := receive(one); len(ids) != 1 || ids[0] != 11 {
+		t.Fatalf("unexpected events for withdrawal 1: %v", ids)
+	}
+
+	// 消费过慢的订阅者被关闭
+	for i := uint(0); i < 3; i++ {
+		if err := db.Create(&model.WithdrawalEvent{ID: 20 + i, WithdrawalID: 1}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hub.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ids := receive(one); len(ids) != 3 || ids[2] != 0 {
+		t.Fatalf("expected slow subscription to be closed, got %v", ids)
+	}
+}
+
+func TestSequence(t *testing.T) {
+	db := openSQLite(t)
+	for _, id := range []uint{5, 3, 4} {
+		if err := db.Create(&model.WithdrawalEvent{ID: id, WithdrawalID: 1}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := Sequence(db); err != nil || n != 3 {
+		t.Fatalf("n=%d, err=%v", n, err)
+	}
+	if err := db.Create(&model.WithdrawalEvent{ID: 1, WithdrawalID: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n, err := Sequence(db); err != nil || n != 1 {
+		t.Fatalf("n=%d, err=%v", n, err)
+	}
+
+	var logged []*model.WithdrawalEvent
+	if err := db.Order("seq").Find(&logged).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := []uint{3, 4, 5, 1}
+	for i, e := range logged {
+		if e.ID != want[i] || e.Seq != uint64(i+1) {
+			t.Fatalf("event %d: id=%d, seq=%d, want id=%d, seq=%d", i, e.ID, e.Seq, want[i], i+1)
+		}
+	}
+}
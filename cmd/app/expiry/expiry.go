@@ -0,0 +1,165 @@
+package expiry
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"task/cmd/app/events"
+	"task/cmd/app/ledger"
+	"task/cmd/app/model"
+	"task/cmd/app/repository"
+
+	"gorm.io/gorm"
+)
+
+// Config 审批和提款申请的有效期，0 表示不过期
+type Config struct {
+	ApprovalWindow time.Duration // 审批有效期，超过后不计入审批数，需要重新审批
+	WithdrawalTTL  time.Duration // 提款申请有效期，超过后仍未上链的提款申请过期
+}
+
+// ApprovalsSince 有效审批的最早时间，不过期时返回零值
+func (c Config) ApprovalsSince(now time.Time) time.Time {
+	if c.ApprovalWindow <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-c.ApprovalWindow)
+}
+
+// ApprovalValid 审批记录是否在有效期内
+func (c Config) ApprovalValid(confirmation *model.WithdrawalConfirmation, now time.Time) bool {
+	return c.ApprovalWindow <= 0 || !confirmation.CreatedAt.Before(now.Add(-c.ApprovalWindow))
+}
+
+// Expired 提款申请是否已超过有效期且尚未上链
+// 已经签名或广播过的提款申请不会过期，由状态机和对账继续处理。
+func (c Config) Expired(withdrawal *model.Withdrawal, now time.Time) bool {
+	if c.WithdrawalTTL <= 0 {
+		return false
+	}
+	return withdrawal.Status == uint64(model.StateUnchained) &&
+		withdrawal.TxHash == "" &&
+		!withdrawal.CreatedAt.After(now.Add(-c.WithdrawalTTL))
+}
+
+// Sweeper 定期把超过有效期的提款申请标记为过期
+type Sweeper struct {
+	db     *gorm.DB
+	repo   repository.WithdrawalRepository
+	cfg    Config
+	events *events.Recorder
+	batch  int // 每次扫描的最大数量
+}
+
+func NewSweeper(db *gorm.DB, repo repository.WithdrawalRepository, cfg Config, recorder *events.Recorder) *Sweeper {
+	return &Sweeper{
+		db:     db,
+		repo:   repo,
+		cfg:    cfg,
+		events: recorder,
+		batch:  100,
+	}
+}
+
+// Sweep 扫描一次，返回过期的提款申请数
+func (s *Sweeper) Sweep(ctx context.Context) (int, error) {
+	if s.cfg.WithdrawalTTL <= 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	var ids []uint64
+	err := s.db.WithContext(ctx).Model(&model.Withdrawal{}).
+		Where("status = ? AND tx_hash = ''", model.StateUnchained).
+		Where("created_at <= ?", now.Add(-s.cfg.WithdrawalTTL)).
+		Order("id").
+		Limit(s.batch).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		ok, err := s.expire(ctx, id, now)
+		if err != nil {
+			slog.ErrorContext(ctx, "expire withdrawal failed", "withdrawal_id", id, "err", err)
+			continue
+		}
+		if ok {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// expire 加行锁后重新检查，与审批、执行请求串行
+// outbox 中有未放弃的交易时说明已经签名，交易可能已经上链，不能过期。
+func (s *Sweeper) expire(ctx context.Context, id uint64, now time.Time) (bool, error) {
+	expired := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		withdrawal, err := s.repo.Lock(tx, id)
+		if err != nil {
+			return err
+		}
+		if !s.cfg.Expired(withdrawal, now) {
+			return nil
+		}
+
+		var pending int64
+		err = tx.Model(&model.Outbox{}).
+			Where("withdrawal_id = ? AND status != ?", id, model.OutboxFailed).
+			Count(&pending).Error
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			return nil
+		}
+
+		withdrawal.Status = uint64(model.StateExpired)
+		err = s.repo.Update(tx, withdrawal, model.StateUnchained, map[string]interface{}{"status": withdrawal.Status})
+		if err != nil {
+			return err
+		}
+		err = ledger.Release(tx, withdrawal, model.StateExpired.String())
+		if err != nil {
+			return err
+		}
+		_, err = s.events.Record(tx, withdrawal, events.TypeStateChanged, map[string]interface{}{
+			"from":   model.StateUnchained.String(),
+			"to":     model.StateExpired.String(),
+			"reason": "ttl",
+		})
+		if err != nil {
+			return err
+		}
+		expired = true
+		return nil
+	})
+	return expired, err
+}
+
+// Schedule 每隔 interval 扫描一次，直到 ctx 结束
+func (s *Sweeper) Schedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := s.Sweep(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "sweep expired withdrawals failed", "err", err)
+			continue
+		}
+		if n > 0 {
+			slog.InfoContext(ctx, "expired withdrawals", "count", n)
+		}
+	}
+}
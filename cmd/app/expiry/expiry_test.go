@@ -0,0 +1,49 @@
+package expiry
+
+import (
+	"testing"
+	"time"
+
+	"task/cmd/app/model"
+)
+
+func TestExpired(t *testing.T) {
+	now := time.Now()
+	cfg := Config{WithdrawalTTL: time.Hour}
+
+	tests := []struct {
+		name       string
+		withdrawal model.Withdrawal
+		want       bool
+	}{
+		{"fresh", model.Withdrawal{CreatedAt: now.Add(-time.Minute)}, false},
+		{"stale", model.Withdrawal{CreatedAt: now.Add(-time.Hour * 2)}, true},
+		{"signed", model.Withdrawal{CreatedAt: now.Add(-time.Hour * 2), TxHash: "0x1"}, false},
+		{"rejected", model.Withdrawal{CreatedAt: now.Add(-time.Hour * 2), Status: uint64(model.StateRejected)}, false},
+	}
+	for _, tt := range tests {
+		if got := cfg.Expired(&tt.withdrawal, now); got != tt.want {
+			t.Errorf("%s: expected %t, got %t", tt.name, tt.want, got)
+		}
+	}
+
+	// 未配置有效期时不过期
+	if (Config{}).Expired(&tests[1].withdrawal, now) {
+		t.Error("expected no expiry without ttl")
+	}
+}
+
+func TestApprovalValid(t *testing.T) {
+	now := time.Now()
+	cfg := Config{ApprovalWindow: time.Hour}
+
+	if !cfg.ApprovalValid(&model.WithdrawalConfirmation{CreatedAt: now.Add(-time.Minute)}, now) {
+		t.Error("expected recent approval to be valid")
+	}
+	if cfg.ApprovalValid(&model.WithdrawalConfirmation{CreatedAt: now.Add(-time.Hour * 2)}, now) {
+		t.Error("expected stale approval to be invalid")
+	}
+	if !(Config{}).ApprovalValid(&model.WithdrawalConfirmation{CreatedAt: now.Add(-time.Hour * 24 * 365)}, now) {
+		t.Error("expected approval to be valid without window")
+	}
+}
@@ -0,0 +1,105 @@
+package fees
+
+import (
+	"sort"
+	"time"
+
+	"task/cmd/app/model"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Row 一个分组的手续费汇总
+type Row struct {
+	Key          string          `json:"key"`          // 日期（UTC，2006-01-02）、发送地址或资产
+	Transactions int             `json:"transactions"` // 已上链的交易数，包括上链失败的交易
+	GasUsed      uint64          `json:"gas_used"`     // 实际使用的 gas 之和
+	FeeWei       decimal.Decimal `json:"fee_wei"`      // 手续费之和，单位 wei
+	FeeEth       decimal.Decimal `json:"fee_eth"`      // 手续费之和，单位 ETH
+}
+
+func (r *Row) add(gasUsed uint64, feeWei decimal.Decimal) {
+	r.Transactions++
+	r.GasUsed += gasUsed
+	r.FeeWei = r.FeeWei.Add(feeWei)
+	r.FeeEth = r.FeeWei.Shift(-18)
+}
+
+// Report 手续费报表
+type Report struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Total    *Row      `json:"total"`
+	ByDay    []*Row    `json:"by_day"`
+	BySender []*Row    `json:"by_sender"`
+	ByAsset  []*Row    `json:"by_asset"`
+}
+
+// attempt 一笔已上链的交易
+type attempt struct {
+	MinedAt     time.Time
+	FromAddress string
+	Asset       string
+	GasUsed     uint64
+	FeeWei      decimal.Decimal
+}
+
+// Build 统计 [from, to) 之间上链的交易的手续费，按日期、发送地址和资产分组
+// 在程序中汇总而不是使用 SUM：SQLite 的 numeric 按浮点数求和会有误差，日期函数也与 postgres 不同。
+func Build(db *gorm.DB, from, to time.Time) (*Report, error) {
+	var attempts []*attempt
+	err := db.Model(&model.Outbox{}).
+		Select("outboxes.mined_at, outboxes.from_address, withdrawals.asset, outboxes.gas_used, outboxes.fee_wei").
+		Joins("JOIN withdrawals ON withdrawals.id = outboxes.withdrawal_id").
+		Where("outboxes.mined_at >= ? AND outboxes.mined_at < ?", from, to).
+		Order("outboxes.mined_at").
+		Scan(&attempts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{From: from, To: to, Total: newRow("total")}
+	byDay := make(map[string]*Row)
+	bySender := make(map[string]*Row)
+	byAsset := make(map[string]*Row)
+	for _, a := range attempts {
+		sender := a.FromAddress
+		if sender == "" {
+			sender = "unknown" // 早于记录发送地址的交易
+		}
+		report.Total.add(a.GasUsed, a.FeeWei)
+		group(byDay, a.MinedAt.UTC().Format("2006-01-02")).add(a.GasUsed, a.FeeWei)
+		group(bySender, sender).add(a.GasUsed, a.FeeWei)
+		group(byAsset, a.Asset).add(a.GasUsed, a.FeeWei)
+	}
+	report.ByDay = sorted(byDay)
+	report.BySender = sorted(bySender)
+	report.ByAsset = sorted(byAsset)
+	return report, nil
+}
+
+func newRow(key string) *Row {
+	return &Row{Key: key, FeeWei: decimal.Zero, FeeEth: decimal.Zero}
+}
+
+func group(rows map[string]*Row, key string) *Row {
+	row, ok := rows[key]
+	if !ok {
+		row = newRow(key)
+		rows[key] = row
+	}
+	return row
+}
+
+// sorted 按 key 排序
+func sorted(rows map[string]*Row) []*Row {
+	result := make([]*Row, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
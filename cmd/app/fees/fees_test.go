@@ -0,0 +1,74 @@
+package fees
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"task/cmd/app/migrate"
+	"task/cmd/app/model"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+func openSQLite(t *testing.T) *gorm.DB {
+	db, err := model.Open(model.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.New(db)
+	if err == nil {
+		_, err = m.Up(context.Background())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestBuild(t *testing.T) {
+	db := openSQLite(t)
+	withdrawal := &model.Withdrawal{Amount: decimal.NewFromInt(1), Asset: model.AssetETH}
+	if err := db.Create(withdrawal).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	mined := func(d time.Duration) *time.Time {
+		at := day.Add(d)
+		return &at
+	}
+	entries := []*model.Outbox{
+		{TxHash: "0x1", FromAddress: "0xa", GasUsed: 21000, FeeWei: decimal.NewFromInt(42_000), MinedAt: mined(time.Hour)},
+		{TxHash: "0x2", FromAddress: "0xa", GasUsed: 21000, FeeWei: decimal.NewFromInt(21_000), MinedAt: mined(time.Hour * 25)},
+		{TxHash: "0x3", FromAddress: "0xb", GasUsed: 30000, FeeWei: decimal.NewFromInt(30_000), MinedAt: mined(time.Hour * 26)},
+		{TxHash: "0x4", FromAddress: "0xb", GasUsed: 30000, FeeWei: decimal.NewFromInt(30_000), MinedAt: mined(time.Hour * 49)}, // 不在统计区间内
+		{TxHash: "0x5", FromAddress: "0xb"}, // 未上链
+	}
+	for _, e := range entries {
+		e.WithdrawalID = uint64(withdrawal.ID)
+		e.RawTx = []byte{1}
+		if err := db.Create(e).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := Build(db, day, day.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total.Transactions != 3 || report.Total.GasUsed != 72000 || report.Total.FeeWei.String() != "93000" {
+		t.Fatalf("unexpected total: %+v", report.Total)
+	}
+	if len(report.ByDay) != 2 || report.ByDay[0].Key != "2024-01-02" || report.ByDay[1].FeeWei.String() != "51000" {
+		t.Fatalf("unexpected by day: %+v", report.ByDay)
+	}
+	if len(report.BySender) != 2 || report.BySender[0].Key != "0xa" || report.BySender[0].Transactions != 2 {
+		t.Fatalf("unexpected by sender: %+v", report.BySender)
+	}
+	if len(report.ByAsset) != 1 || report.ByAsset[0].Key != model.AssetETH {
+		t.Fatalf("unexpected by asset: %+v", report.ByAsset)
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"task/cmd/app/apierr"
+	"task/cmd/app/auth"
+	"task/cmd/app/model"
+	"task/cmd/app/pb"
+	"task/cmd/app/requestid"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcPermissions 各方法所需权限，与 REST 路由一致；未列出的方法只需要认证
+var grpcPermissions = map[string]auth.Permission{
+	pb.WithdrawalService_Create_FullMethodName:         auth.PermCreate,
+	pb.WithdrawalService_Approve_FullMethodName:        auth.PermApprove,
+	pb.WithdrawalService_RevokeApproval_FullMethodName: auth.PermApprove,
+	pb.WithdrawalService_Reject_FullMethodName:         auth.PermApprove,
+	pb.WithdrawalService_Execute_FullMethodName:        auth.PermExecute,
+}
+
+// grpcServer 实现 pb.WithdrawalServiceServer，业务逻辑由 WithdrawalService 处理
+type grpcServer struct {
+	pb.UnimplementedWithdrawalServiceServer
+	svc            *WithdrawalService
+	d              *dependencies
+	roles          auth.RoleStore
+	authenticators []auth.Authenticator
+}
+
+// newGRPCServer 创建 gRPC 服务，认证、权限和错误语义与 REST 接口相同
+func newGRPCServer(d *dependencies) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(unaryRequestID),
+		grpc.StreamInterceptor(streamRequestID),
+	)
+	pb.RegisterWithdrawalServiceServer(s, &grpcServer{
+		svc:            newWithdrawalService(d),
+		d:              d,
+		roles:          d.roles,
+		authenticators: d.authenticators,
+	})
+	return s
+}
+
+// authorize 认证并校验权限
+// metadata 转换为请求头，方法名作为请求路径，请求消息的 protobuf 编码作为请求体，
+// 因此 HMAC 签名的 method 为 POST，uri 为完整方法名，请求体需要使用确定性编码。
+func (g *grpcServer) authorize(ctx context.Context, method string, req proto.Message) (*auth.Identity, error) {
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return nil, grpcError(ctx, apierr.InvalidRequest(err))
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, method, bytes.NewReader(body))
+	if err != nil {
+		return nil, grpcError(ctx, apierr.InvalidRequest(err))
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, values := range md {
+		for _, v := range values {
+			r.Header.Add(k, v)
+		}
+	}
+
+	identity, err := auth.Authenticate(r, g.roles, g.authenticators...)
+	if errors.Is(err, auth.ErrNoCredentials) || errors.Is(err, auth.ErrInvalidCredentials) {
+		return nil, grpcError(ctx, apierr.Wrap(apierr.CodeUnauthorized, "unauthorized", err))
+	}
+	if err != nil {
+		return nil, grpcError(ctx, apierr.Internal("load roles failed", err))
+	}
+
+	if perm, ok := grpcPermissions[method]; ok && !identity.Can(perm) {
+		slog.WarnContext(ctx, "forbidden", "user_id", identity.UserID, "permission", perm)
+		return nil, grpcError(ctx, apierr.New(apierr.CodeForbidden, "forbidden"))
+	}
+	return identity, nil
+}
+
+func (g *grpcServer) Create(ctx context.Context, req *pb.CreateRequest) (*pb.CreateResponse, error) {
+	identity, err := g.authorize(ctx, pb.WithdrawalService_Create_FullMethodName, req)
+	if err != nil {
+		return nil, err
+	}
+
+	withdrawal, err := g.svc.Create(ctx, identity, &WithdrawalRequest{Amount: req.Amount, To: req.To, Asset: req.Asset})
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return &pb.CreateResponse{RequestId: uint64(withdrawal.ID)}, nil
+}
+
+func (g *grpcServer) Get(ctx context.Context, req *pb.GetRequest) (*pb.Withdrawal, error) {
+	identity, err := g.authorize(ctx, pb.WithdrawalService_Get_FullMethodName, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.RequestId == 0 {
+		return nil, grpcError(ctx, apierr.InvalidRequest(nil))
+	}
+
+	withdrawals, err := g.svc.List(ctx, identity, req.RequestId)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	if len(withdrawals) == 0 {
+		return nil, grpcError(ctx, apierr.New(apierr.CodeWithdrawalNotFound, "withdrawal not found"))
+	}
+	return toPBWithdrawal(withdrawals[0]), nil
+}
+
+func (g *grpcServer) List(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
+	identity, err := g.authorize(ctx, pb.WithdrawalService_List_FullMethodName, req)
+	if err != nil {
+		return nil, err
+	}
+
+	withdrawals, err := g.svc.List(ctx, identity, 0)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	resp := &pb.ListResponse{}
+	for _, withdrawal := range withdrawals {
+		resp.Withdrawals = append(resp.Withdrawals, toPBWithdrawal(withdrawal))
+	}
+	return resp, nil
+}
+
+func (g *grpcServer) Approve(ctx context.Context, req *pb.ApproveRequest) (*pb.ApproveResponse, error) {
+	identity, err := g.authorize(ctx, pb.WithdrawalService_Approve_FullMethodName, req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := g.svc.Approve(ctx, identity, req.RequestId)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return toPBApproveResponse(result), nil
+}
+
+func (g *grpcServer) RevokeApproval(ctx context.Context, req *pb.RevokeApprovalRequest) (*pb.ApproveResponse, error) {
+	identity, err := g.authorize(ctx, pb.WithdrawalService_RevokeApproval_FullMethodName, req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := g.svc.RevokeApproval(ctx, identity, req.RequestId)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return toPBApproveResponse(result), nil
+}
+
+func (g *grpcServer) Reject(ctx context.Context, req *pb.RejectRequest) (*pb.Withdrawal, error) {
+	identity, err := g.authorize(ctx, pb.WithdrawalService_Reject_FullMethodName, req)
+	if err != nil {
+		return nil, err
+	}
+
+	withdrawal, err := g.svc.Reject(ctx, identity, req.RequestId, req.Reason)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return toPBWithdrawal(withdrawal), nil
+}
+
+func (g *grpcServer) Execute(ctx context.Context, req *pb.ExecuteRequest) (*pb.Withdrawal, error) {
+	identity, err := g.authorize(ctx, pb.WithdrawalService_Execute_FullMethodName, req)
+	if err != nil {
+		return nil, err
+	}
+
+	withdrawal, err := g.svc.Execute(ctx, identity, req.RequestId)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return toPBWithdrawal(withdrawal), nil
+}
+
+func (g *grpcServer) WatchWithdrawal(req *pb.WatchRequest, stream pb.WithdrawalService_WatchWithdrawalServer) error {
+	ctx := stream.Context()
+	identity, err := g.authorize(ctx, pb.WithdrawalService_WatchWithdrawal_FullMethodName, req)
+	if err != nil {
+		return err
+	}
+	err = g.svc.CanWatch(identity, req.RequestId)
+	if err != nil {
+		return grpcError(ctx, err)
+	}
+
+	send := func(event *model.WithdrawalEvent) error {
+		return stream.Send(toPBEvent(event))
+	}
+	err = watchEvents(ctx, g.d.db, g.d.hub, req.RequestId, req.LastEventId, send, func() {}, nil)
+	if err != nil && ctx.Err() == nil {
+		return grpcError(ctx, err)
+	}
+	return nil
+}
+
+// grpcError 按与 REST 相同的 HTTP 状态码映射 gRPC 状态码
+// 错误码放在 ErrorInfo.Reason，请求 ID 和附加信息放在 ErrorInfo.Metadata，字段级错误放在 BadRequest 中。
+// 并发修改冲突返回 Aborted，按 gRPC 的约定由调用方重试整个请求。
+func grpcError(ctx context.Context, err error) error {
+	httpStatus, body := apierr.Response(err, requestid.From(ctx))
+
+	code := grpcCode(httpStatus)
+	if body.Code == apierr.CodeConcurrentUpdate {
+		code = codes.Aborted
+	}
+	st := status.New(code, body.Message)
+	info := &errdetails.ErrorInfo{
+		Reason:   string(body.Code),
+		Domain:   "withdrawal.v1",
+		Metadata: map[string]string{"request_id": body.RequestID},
+	}
+	for k, v := range body.Meta {
+		if s, ok := v.(string); ok {
+			info.Metadata[k] = s
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			b = []byte(fmt.Sprint(v))
+		}
+		info.Metadata[k] = string(b)
+	}
+	details := []protoadapt.MessageV1{info}
+	if len(body.Details) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, d := range body.Details {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       d.Field,
+				Description: d.Message,
+			})
+		}
+		details = append(details, badRequest)
+	}
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict, http.StatusUnprocessableEntity:
+		return codes.FailedPrecondition
+	default:
+		return codes.Internal
+	}
+}
+
+// requestIDFromMetadata 沿用调用方的 x-request-id，否则生成，并通过响应 header 返回
+func requestIDFromMetadata(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestid.Header); len(values) > 0 && len(values[0]) <= 128 {
+			id = values[0]
+		}
+	}
+	if id == "" {
+		id = requestid.New()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestid.Header, id))
+	return requestid.With(ctx, id)
+}
+
+func unaryRequestID(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(requestIDFromMetadata(ctx), req)
+}
+
+func streamRequestID(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &requestIDStream{ServerStream: ss, ctx: requestIDFromMetadata(ss.Context())})
+}
+
+// requestIDStream 替换 ServerStream 的 context
+type requestIDStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestIDStream) Context() context.Context {
+	return s.ctx
+}
+
+func toPBWithdrawal(w *model.Withdrawal) *pb.Withdrawal {
+	return &pb.Withdrawal{
+		Id:                uint64(w.ID),
+		Amount:            w.Amount.String(),
+		AmountBase:        w.AmountBase.String(),
+		TxHash:            w.TxHash,
+		Status:            w.Status,
+		State:             model.WithdrawalState(w.Status).String(),
+		CreatedBy:         w.CreatedBy,
+		ToAddress:         w.ToAddress,
+		RequiredApprovals: w.RequiredApprovals,
+		CreatedAt:         timestamppb.New(w.CreatedAt),
+		UpdatedAt:         timestamppb.New(w.UpdatedAt),
+		Asset:             w.Asset,
+		GasLimit:          w.GasLimit,
+		GasUsed:           w.GasUsed,
+		EffectiveGasPrice: w.EffectiveGasPrice.String(),
+		FeeWei:            w.FeeWei.String(),
+	}
+}
+
+func toPBApproveResponse(result *ApprovalResult) *pb.ApproveResponse {
+	return &pb.ApproveResponse{
+		Withdrawal:      toPBWithdrawal(result.Withdrawal),
+		Executed:        result.Executed,
+		AlreadyApproved: result.AlreadyApproved,
+		Approvals:       uint64(result.Approvals),
+	}
+}
+
+func toPBEvent(e *model.WithdrawalEvent) *pb.WithdrawalEvent {
+	return &pb.WithdrawalEvent{
+		Id:           uint64(e.ID),
+		WithdrawalId: e.WithdrawalID,
+		Type:         e.Type,
+		State:        model.WithdrawalState(e.Status).String(),
+		Status:       e.Status,
+		TxHash:       e.TxHash,
+		Data:         e.Data,
+		CreatedAt:    timestamppb.New(e.CreatedAt),
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"task/cmd/app/auth"
+	"task/cmd/app/limits"
+	"task/cmd/app/pb"
+	"task/cmd/app/repository"
+	"task/cmd/app/requestid"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// staticRoleStore 测试用，所有用户的角色相同
+type staticRoleStore []auth.Role
+
+func (s staticRoleStore) Roles(uint64) ([]auth.Role, error) {
+	return s, nil
+}
+
+func newTestGRPCClient(t *testing.T, d *dependencies) pb.WithdrawalServiceClient {
+	lis := bufconn.Listen(1 << 20)
+	srv := newGRPCServer(d)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial failed: err=%v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewWithdrawalServiceClient(conn)
+}
+
+func TestGRPCAuth(t *testing.T) {
+	client := newTestGRPCClient(t, &dependencies{
+		authenticators: []auth.Authenticator{headerAuthenticator{}},
+	})
+
+	_, err := client.Create(context.Background(), &pb.CreateRequest{Amount: "1"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+
+	// 与 REST 相同：金额不合法返回 invalid request
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-test-user", "1")
+	_, err = client.Create(ctx, &pb.CreateRequest{Amount: "-1"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+
+	requester := newTestGRPCClient(t, &dependencies{
+		roles:          staticRoleStore{auth.RoleRequester},
+		authenticators: []auth.Authenticator{headerAuthenticator{}},
+	})
+	_, err = requester.Execute(ctx, &pb.ExecuteRequest{RequestId: 1})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied, got %v", err)
+	}
+	_, err = requester.RevokeApproval(ctx, &pb.RevokeApprovalRequest{RequestId: 1})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied, got %v", err)
+	}
+
+	// 订阅所有提款申请需要 read-all 权限，服务端流的错误在 Recv 时返回
+	stream, err := requester.WatchWithdrawal(ctx, &pb.WatchRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied, got %v", err)
+	}
+}
+
+func TestGRPCError(t *testing.T) {
+	ctx := requestid.With(context.Background(), "req-1")
+	err := grpcError(ctx, limitError(&limits.ExceededError{Limit: limits.LimitMaxSingle}))
+	st := status.Convert(err)
+	if st.Code() != codes.FailedPrecondition || st.Message() != "limit exceeded" {
+		t.Fatalf("unexpected status: %v", st)
+	}
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("expected 1 detail, got %d", len(details))
+	}
+	info, ok := details[0].(*errdetails.ErrorInfo)
+	if !ok || info.Reason != "LIMIT_EXCEEDED" || info.Metadata["request_id"] != "req-1" || info.Metadata["limit"] == "" {
+		t.Fatalf("unexpected detail: %v", details[0])
+	}
+
+	if code := grpcCode(http.StatusNotFound); code != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", code)
+	}
+
+	// 并发修改冲突返回 Aborted，提示可以重试
+	st = status.Convert(grpcError(ctx, updateError("reject withdrawal failed", repository.ErrConflict)))
+	if st.Code() != codes.Aborted {
+		t.Fatalf("expected Aborted, got %v", st)
+	}
+	info, ok = st.Details()[0].(*errdetails.ErrorInfo)
+	if !ok || info.Reason != "CONCURRENT_UPDATE" || info.Metadata["retryable"] != "true" {
+		t.Fatalf("unexpected detail: %v", st.Details()[0])
+	}
+}
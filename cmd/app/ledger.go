@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"task/cmd/app/apierr"
+	"task/cmd/app/auth"
+	"task/cmd/app/ledger"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// registerLedgerRoutes 注册账本路由
+// 用户可以查询自己的账户，系统账户和其他用户的账户需要 read-all 权限。
+func registerLedgerRoutes(r *gin.Engine, db *gorm.DB) {
+	// 查询账户余额 (GET /ledger/accounts/{name})，如 user:1:available、user:1:reserved、system:gas_fees
+	r.GET("/ledger/accounts/:name", func(c *gin.Context) {
+		identity := auth.IdentityFrom(c)
+		account, err := ledger.Account(db, c.Param("name"))
+		if err == nil && !identity.Can(auth.PermReadAll) && account.UserID != identity.UserID {
+			// 不暴露其他用户的账户是否存在
+			err = gorm.ErrRecordNotFound
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierr.Respond(c, apierr.New(apierr.CodeAccountNotFound, "account not found"))
+			return
+		}
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("find account failed", err))
+			return
+		}
+
+		balance, err := ledger.Balance(db, account)
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("query balance failed", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"account": account,
+			"balance": balance,
+		})
+	})
+}
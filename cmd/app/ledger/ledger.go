@@ -0,0 +1,233 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+
+	"task/cmd/app/model"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 记账凭证类型
+const (
+	KindReserve = "reserve" // 创建提款申请时从可用余额预留
+	KindSettle  = "settle"  // 上链成功，预留转为已转出，并扣除实际手续费
+	KindRelease = "release" // 拒绝、过期或上链失败，预留退回可用余额
+)
+
+// 系统账户
+const (
+	AccountWithdrawn = "system:withdrawn" // 已转出到链上的金额
+	AccountGasFees   = "system:gas_fees"  // 已支付的手续费
+)
+
+// Available 用户可用余额账户
+// 入金不在本系统中记账，可用余额为负表示累计提款超过了在本系统中记录的入金。
+func Available(userID uint64) string {
+	return fmt.Sprintf("user:%d:available", userID)
+}
+
+// Reserved 用户已预留、尚未上链的金额
+func Reserved(userID uint64) string {
+	return fmt.Sprintf("user:%d:reserved", userID)
+}
+
+// ErrUnbalanced 分录金额之和不为 0
+var ErrUnbalanced = errors.New("ledger unbalanced")
+
+// posting 待写入的分录
+type posting struct {
+	account string
+	userID  uint64
+	amount  decimal.Decimal
+}
+
+// Reserve 从发起人可用余额预留提款金额，已有预留时不重复预留
+// 需要在持有提款申请行锁的事务中调用，下同。
+func Reserve(tx *gorm.DB, withdrawal *model.Withdrawal) error {
+	held, err := Held(tx, withdrawal)
+	if err != nil || held.IsPositive() {
+		return err
+	}
+	user := withdrawal.CreatedBy
+	return post(tx, withdrawal, KindReserve, "",
+		posting{Available(user), user, withdrawal.Amount.Neg()},
+		posting{Reserved(user), user, withdrawal.Amount},
+	)
+}
+
+// Settle 上链成功后结算：预留转为已转出，手续费从可用余额扣除，每个提款申请只结算一次
+// 没有预留（预留已释放或提款申请早于账本创建）的部分直接从可用余额扣除。
+func Settle(tx *gorm.DB, withdrawal *model.Withdrawal, fee decimal.Decimal) error {
+	var settled int64
+	err := tx.Model(&model.JournalEntry{}).
+		Where("withdrawal_id = ? AND kind = ?", withdrawal.ID, KindSettle).
+		Count(&settled).Error
+	if err != nil || settled > 0 {
+		return err
+	}
+
+	held, err := Held(tx, withdrawal)
+	if err != nil {
+		return err
+	}
+	user := withdrawal.CreatedBy
+	return post(tx, withdrawal, KindSettle, "fee="+fee.String(),
+		posting{Reserved(user), user, held.Neg()},
+		posting{Available(user), user, withdrawal.Amount.Sub(held).Add(fee).Neg()},
+		posting{AccountWithdrawn, 0, withdrawal.Amount},
+		posting{AccountGasFees, 0, fee},
+	)
+}
+
+// Release 释放预留，退回可用余额，没有预留时不做任何操作
+func Release(tx *gorm.DB, withdrawal *model.Withdrawal, reason string) error {
+	held, err := Held(tx, withdrawal)
+	if err != nil || !held.IsPositive() {
+		return err
+	}
+	user := withdrawal.CreatedBy
+	return post(tx, withdrawal, KindRelease, reason,
+		posting{Reserved(user), user, held.Neg()},
+		posting{Available(user), user, held},
+	)
+}
+
+// Held 提款申请当前的预留金额
+func Held(db *gorm.DB, withdrawal *model.Withdrawal) (decimal.Decimal, error) {
+	return sum(db.Model(&model.Posting{}).
+		Joins("JOIN journal_entries ON journal_entries.id = postings.journal_entry_id").
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = postings.account_id").
+		Where("journal_entries.withdrawal_id = ?", withdrawal.ID).
+		Where("ledger_accounts.name = ?", Reserved(withdrawal.CreatedBy)))
+}
+
+// Account 按名称查询账户，不存在时返回 gorm.ErrRecordNotFound
+func Account(db *gorm.DB, name string) (*model.LedgerAccount, error) {
+	account := &model.LedgerAccount{}
+	err := db.Where("name = ?", name).First(account).Error
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// Balance 账户余额
+func Balance(db *gorm.DB, account *model.LedgerAccount) (decimal.Decimal, error) {
+	return sum(db.Model(&model.Posting{}).Where("account_id = ?", account.ID))
+}
+
+// Report 账本校验结果
+type Report struct {
+	Entries    int             `json:"entries"`    // 记账凭证数
+	Postings   int             `json:"postings"`   // 分录数
+	Total      decimal.Decimal `json:"total"`      // 所有分录金额之和，应为 0
+	Unbalanced []uint64        `json:"unbalanced"` // 分录金额之和不为 0 的记账凭证
+}
+
+// Check 校验每个记账凭证以及整个账本的分录金额之和都为 0，不平衡时返回 ErrUnbalanced
+func Check(db *gorm.DB) (*Report, error) {
+	rows, err := db.Model(&model.Posting{}).
+		Select("journal_entry_id, amount").
+		Order("journal_entry_id").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &Report{Total: decimal.Zero, Unbalanced: []uint64{}}
+	entries := make(map[uint64]decimal.Decimal)
+	for rows.Next() {
+		var entryID uint64
+		var amount decimal.Decimal
+		err = rows.Scan(&entryID, &amount)
+		if err != nil {
+			return nil, err
+		}
+		report.Postings++
+		report.Total = report.Total.Add(amount)
+		entries[entryID] = entries[entryID].Add(amount)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report.Entries = len(entries)
+	for id, total := range entries {
+		if !total.IsZero() {
+			report.Unbalanced = append(report.Unbalanced, id)
+		}
+	}
+	if len(report.Unbalanced) > 0 || !report.Total.IsZero() {
+		return report, fmt.Errorf("%w: total=%s, unbalanced entries=%v", ErrUnbalanced, report.Total, report.Unbalanced)
+	}
+	return report, nil
+}
+
+// post 写入记账凭证和分录，分录金额之和必须为 0，金额为 0 的分录不写入
+func post(tx *gorm.DB, withdrawal *model.Withdrawal, kind, memo string, postings ...posting) error {
+	total := decimal.Zero
+	for _, p := range postings {
+		total = total.Add(p.amount)
+	}
+	if !total.IsZero() {
+		return fmt.Errorf("%w: kind=%s, total=%s", ErrUnbalanced, kind, total)
+	}
+
+	entry := &model.JournalEntry{
+		WithdrawalID: uint64(withdrawal.ID),
+		Kind:         kind,
+		Memo:         memo,
+	}
+	err := tx.Create(entry).Error
+	if err != nil {
+		return err
+	}
+	for _, p := range postings {
+		if p.amount.IsZero() {
+			continue
+		}
+		account, err := ensureAccount(tx, p.account, p.userID)
+		if err != nil {
+			return err
+		}
+		err = tx.Create(&model.Posting{
+			JournalEntryID: uint64(entry.ID),
+			AccountID:      uint64(account.ID),
+			Amount:         p.amount,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureAccount 查询账户，不存在时创建
+func ensureAccount(tx *gorm.DB, name string, userID uint64) (*model.LedgerAccount, error) {
+	err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).
+		Create(&model.LedgerAccount{Name: name, UserID: userID}).Error
+	if err != nil {
+		return nil, err
+	}
+	return Account(tx, name)
+}
+
+// sum 分录金额之和
+// 在程序中求和而不是使用 SUM：SQLite 的 numeric 按浮点数求和会有误差。
+func sum(query *gorm.DB) (decimal.Decimal, error) {
+	var amounts []decimal.Decimal
+	err := query.Pluck("postings.amount", &amounts).Error
+	if err != nil {
+		return decimal.Zero, err
+	}
+	total := decimal.Zero
+	for _, amount := range amounts {
+		total = total.Add(amount)
+	}
+	return total, nil
+}
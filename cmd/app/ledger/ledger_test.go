@@ -0,0 +1,100 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"task/cmd/app/migrate"
+	"task/cmd/app/model"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+func openSQLite(t *testing.T) *gorm.DB {
+	db, err := model.Open(model.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.New(db)
+	if err == nil {
+		_, err = m.Up(context.Background())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func expectBalance(t *testing.T, db *gorm.DB, name, want string) {
+	t.Helper()
+	account, err := Account(db, name)
+	if err != nil {
+		t.Fatalf("find account %s failed: %v", name, err)
+	}
+	balance, err := Balance(db, account)
+	if err != nil || !balance.Equal(decimal.RequireFromString(want)) {
+		t.Fatalf("%s: expected %s, got %s, err=%v", name, want, balance, err)
+	}
+}
+
+func TestLedger(t *testing.T) {
+	db := openSQLite(t)
+
+	settled := &model.Withdrawal{ID: 1, CreatedBy: 7, Amount: decimal.RequireFromString("1.5")}
+	released := &model.Withdrawal{ID: 2, CreatedBy: 7, Amount: decimal.RequireFromString("0.3")}
+	for _, w := range []*model.Withdrawal{settled, released, settled} {
+		if err := Reserve(db, w); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectBalance(t, db, Available(7), "-1.8")
+	expectBalance(t, db, Reserved(7), "1.8")
+
+	// 结算和释放都只生效一次
+	for i := 0; i < 2; i++ {
+		if err := Settle(db, settled, decimal.RequireFromString("0.000021")); err != nil {
+			t.Fatal(err)
+		}
+		if err := Release(db, released, "rejected"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectBalance(t, db, Available(7), "-1.500021")
+	expectBalance(t, db, Reserved(7), "0")
+	expectBalance(t, db, AccountWithdrawn, "1.5")
+	expectBalance(t, db, AccountGasFees, "0.000021")
+
+	// 没有预留时直接从可用余额扣减
+	unreserved := &model.Withdrawal{ID: 3, CreatedBy: 7, Amount: decimal.NewFromInt(1)}
+	if err := Settle(db, unreserved, decimal.Zero); err != nil {
+		t.Fatal(err)
+	}
+	expectBalance(t, db, Available(7), "-2.500021")
+
+	report, err := Check(db)
+	if err != nil || !report.Total.IsZero() || report.Entries != 5 {
+		t.Fatalf("unexpected report: %+v, err=%v", report, err)
+	}
+
+	// 不平衡的分录被发现
+	err = db.Create(&model.Posting{JournalEntryID: 1, AccountID: 1, Amount: decimal.NewFromInt(1)}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = Check(db)
+	if !errors.Is(err, ErrUnbalanced) || len(report.Unbalanced) != 1 || report.Unbalanced[0] != 1 {
+		t.Fatalf("expected unbalanced entry 1, got %+v, err=%v", report, err)
+	}
+}
+
+func TestPostUnbalanced(t *testing.T) {
+	db := openSQLite(t)
+	w := &model.Withdrawal{ID: 1, CreatedBy: 1, Amount: decimal.NewFromInt(1)}
+	err := post(db, w, KindReserve, "", posting{Available(1), 1, decimal.NewFromInt(-1)})
+	if !errors.Is(err, ErrUnbalanced) {
+		t.Fatalf("expected ErrUnbalanced, got %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package limits
+
+import (
+	"fmt"
+	"time"
+
+	"task/cmd/app/model"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// 限额名称，出现在 ExceededError.Limit 中
+const (
+	LimitMaxSingle          = "max_single"           // 单笔上限
+	LimitRequester24h       = "requester_24h"        // 发起人 24 小时累计
+	LimitRequester7d        = "requester_7d"         // 发起人 7 天累计
+	LimitDestination24h     = "destination_24h"      // 收款地址 24 小时累计
+	LimitDestination7d      = "destination_7d"       // 收款地址 7 天累计
+	LimitGlobalDaily        = "global_daily"         // 全局 24 小时流出
+	LimitRequesterCountHour = "requester_count_hour" // 发起人每小时笔数
+)
+
+// Config 限额配置，零值表示不限制
+type Config struct {
+	MaxSingle         decimal.Decimal
+	PerRequester24h   decimal.Decimal
+	PerRequester7d    decimal.Decimal
+	PerDestination24h decimal.Decimal
+	PerDestination7d  decimal.Decimal
+	GlobalDaily       decimal.Decimal
+	MaxCountPerHour   int64
+}
+
+// ExceededError 超出限额
+type ExceededError struct {
+	Limit     string          `json:"limit"`     // 触发的限额
+	Max       decimal.Decimal `json:"max"`       // 限额
+	Used      decimal.Decimal `json:"used"`      // 窗口内已使用
+	Requested decimal.Decimal `json:"requested"` // 本次申请
+	Remaining decimal.Decimal `json:"remaining"` // 剩余额度
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("limit exceeded: limit=%s, max=%s, used=%s, requested=%s, remaining=%s",
+		e.Limit, e.Max, e.Used, e.Requested, e.Remaining)
+}
+
+// lockClass postgres advisory lock 的第一个 key，第二个 key 为发起人、收款地址或全局的哈希
+const lockClass = 72_657_003
+
+// Checker 校验提款申请是否超出限额
+// 窗口内的累计金额按提款申请创建时间统计，创建和执行时各校验一次；
+// 执行时提款申请本身已经入库，统计时排除自身，避免重复计算。
+type Checker struct {
+	cfg Config
+	now func() time.Time
+}
+
+func NewChecker(cfg Config) *Checker {
+	return &Checker{cfg: cfg, now: time.Now}
+}
+
+// Check 校验 withdrawal，超出限额时返回 *ExceededError
+// db 必须是插入或执行该提款申请的事务：统计前按发起人、收款地址和全局加锁，直到事务结束，
+// 并发的请求依次统计，不会各自通过校验后合计超出限额。
+// 全局锁会让所有创建和执行请求排队，调用方校验后应尽快提交，不能在事务中广播交易或等待 receipt。
+func (c *Checker) Check(db *gorm.DB, withdrawal *model.Withdrawal) error {
+	amount := withdrawal.Amount
+	now := c.now()
+
+	if !c.cfg.MaxSingle.IsZero() && amount.GreaterThan(c.cfg.MaxSingle) {
+		return exceeded(LimitMaxSingle, c.cfg.MaxSingle, decimal.Zero, amount)
+	}
+
+	err := c.lock(db, withdrawal)
+	if err != nil {
+		return err
+	}
+
+	sums := []struct {
+		limit  string
+		max    decimal.Decimal
+		window time.Duration
+		column string
+		value  interface{}
+	}{
+		{LimitRequester24h, c.cfg.PerRequester24h, time.Hour * 24, "created_by", withdrawal.CreatedBy},
+		{LimitRequester7d, c.cfg.PerRequester7d, time.Hour * 24 * 7, "created_by", withdrawal.CreatedBy},
+		{LimitDestination24h, c.cfg.PerDestination24h, time.Hour * 24, "to_address", withdrawal.ToAddress},
+		{LimitDestination7d, c.cfg.PerDestination7d, time.Hour * 24 * 7, "to_address", withdrawal.ToAddress},
+		{LimitGlobalDaily, c.cfg.GlobalDaily, time.Hour * 24, "", nil},
+	}
+	for _, s := range sums {
+		if s.max.IsZero() {
+			continue
+		}
+		query := c.window(db, withdrawal, now.Add(-s.window))
+		if s.column != "" {
+			query = query.Where(s.column+" = ?", s.value)
+		}
+		var used decimal.Decimal
+		err := query.Select("COALESCE(SUM(CAST(amount AS numeric)), 0)").Scan(&used).Error
+		if err != nil {
+			return err
+		}
+		if used.Add(amount).GreaterThan(s.max) {
+			return exceeded(s.limit, s.max, used, amount)
+		}
+	}
+
+	if c.cfg.MaxCountPerHour > 0 {
+		var count int64
+		err := c.window(db, withdrawal, now.Add(-time.Hour)).
+			Where("created_by = ?", withdrawal.CreatedBy).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count+1 > c.cfg.MaxCountPerHour {
+			return exceeded(LimitRequesterCountHour,
+				decimal.NewFromInt(c.cfg.MaxCountPerHour), decimal.NewFromInt(count), decimal.NewFromInt(1))
+		}
+	}
+
+	return nil
+}
+
+// lock 对启用了限额的发起人、收款地址和全局窗口加事务级 advisory lock，按固定顺序加锁避免死锁
+// SQLite 的写事务在开始时即获取写锁（见 model.sqliteDSN），本身是串行的，不需要加锁。
+func (c *Checker) lock(db *gorm.DB, withdrawal *model.Withdrawal) error {
+	if db.Dialector.Name() != model.DriverPostgres {
+		return nil
+	}
+
+	var keys []string
+	if !c.cfg.PerRequester24h.IsZero() || !c.cfg.PerRequester7d.IsZero() || c.cfg.MaxCountPerHour > 0 {
+		keys = append(keys, fmt.Sprintf("requester:%d", withdrawal.CreatedBy))
+	}
+	if !c.cfg.PerDestination24h.IsZero() || !c.cfg.PerDestination7d.IsZero() {
+		keys = append(keys, "destination:"+withdrawal.ToAddress)
+	}
+	if !c.cfg.GlobalDaily.IsZero() {
+		keys = append(keys, "global")
+	}
+	for _, key := range keys {
+		err := db.Exec("SELECT pg_advisory_xact_lock(CAST(? AS integer), hashtext(?))", lockClass, key).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// window 查询 since 之后创建的其他提款申请，已拒绝和已过期的不计入
+func (c *Checker) window(db *gorm.DB, withdrawal *model.Withdrawal, since time.Time) *gorm.DB {
+	query := db.Model(&model.Withdrawal{}).
+		Where("created_at >= ?", since).
+		Where("status NOT IN ?", []uint64{uint64(model.StateRejected), uint64(model.StateExpired)})
+	if withdrawal.ID != 0 {
+		query = query.Where("id != ?", withdrawal.ID)
+	}
+	return query
+}
+
+func exceeded(limit string, max, used, requested decimal.Decimal) *ExceededError {
+	remaining := max.Sub(used)
+	if remaining.IsNegative() {
+		remaining = decimal.Zero
+	}
+	return &ExceededError{
+		Limit:     limit,
+		Max:       max,
+		Used:      used,
+		Requested: requested,
+		Remaining: remaining,
+	}
+}
@@ -0,0 +1,123 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"task/cmd/app/migrate"
+	"task/cmd/app/model"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+func openSQLite(t *testing.T) *gorm.DB {
+	db, err := model.Open(model.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.New(db)
+	if err == nil {
+		_, err = m.Up(context.Background())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestCheckMaxSingle(t *testing.T) {
+	c := NewChecker(Config{MaxSingle: decimal.NewFromInt(10)})
+
+	// 单笔上限不需要查询数据库
+	err := c.Check(nil, &model.Withdrawal{Amount: decimal.NewFromInt(11)})
+	var exceededErr *ExceededError
+	if !errors.As(err, &exceededErr) {
+		t.Fatalf("err=%v", err)
+	}
+	if exceededErr.Limit != LimitMaxSingle || !exceededErr.Remaining.Equal(decimal.NewFromInt(10)) {
+		t.Fatalf("unexpected error: %+v", exceededErr)
+	}
+}
+
+func TestExceededRemaining(t *testing.T) {
+	e := exceeded(LimitRequester24h, decimal.NewFromInt(100), decimal.NewFromInt(70), decimal.NewFromInt(50))
+	if !e.Remaining.Equal(decimal.NewFromInt(30)) {
+		t.Fatalf("remaining=%s", e.Remaining)
+	}
+
+	// 限额调低后已使用可能超过限额，剩余额度不为负
+	e = exceeded(LimitRequester24h, decimal.NewFromInt(100), decimal.NewFromInt(120), decimal.NewFromInt(1))
+	if !e.Remaining.IsZero() {
+		t.Fatalf("remaining=%s", e.Remaining)
+	}
+}
+
+func TestCheckWindows(t *testing.T) {
+	db := openSQLite(t)
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	const alice, bob = "0x00000000000000000000000000000000000000a1", "0x00000000000000000000000000000000000000b2"
+
+	// 发起人 1：1 小时内 6，25 小时前 5，8 天前 100（两个窗口之外），1 小时内已拒绝的 50 不计入
+	// 发起人 2：2 小时前 3，收款地址与发起人 1 相同
+	existing := []model.Withdrawal{
+		{CreatedAt: now.Add(-time.Minute * 30), Amount: decimal.NewFromInt(6), CreatedBy: 1, ToAddress: alice},
+		{CreatedAt: now.Add(-time.Hour * 25), Amount: decimal.NewFromInt(5), CreatedBy: 1, ToAddress: bob},
+		{CreatedAt: now.Add(-time.Hour * 24 * 8), Amount: decimal.NewFromInt(100), CreatedBy: 1, ToAddress: alice},
+		{CreatedAt: now.Add(-time.Minute * 10), Amount: decimal.NewFromInt(50), CreatedBy: 1, ToAddress: alice, Status: uint64(model.StateRejected)},
+		{CreatedAt: now.Add(-time.Hour * 2), Amount: decimal.NewFromInt(3), CreatedBy: 2, ToAddress: alice},
+	}
+	if err := db.Create(&existing).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		cfg    Config
+		amount int64
+		limit  string // 为空表示不超出
+		used   int64
+	}{
+		{"requester 24h within", Config{PerRequester24h: decimal.NewFromInt(10)}, 4, "", 0},
+		{"requester 24h exceeded", Config{PerRequester24h: decimal.NewFromInt(10)}, 5, LimitRequester24h, 6},
+		{"requester 7d exceeded", Config{PerRequester7d: decimal.NewFromInt(12)}, 2, LimitRequester7d, 11},
+		{"destination 24h exceeded", Config{PerDestination24h: decimal.NewFromInt(10)}, 2, LimitDestination24h, 9},
+		{"destination 7d within", Config{PerDestination7d: decimal.NewFromInt(10)}, 1, "", 0},
+		{"global daily exceeded", Config{GlobalDaily: decimal.NewFromInt(10)}, 2, LimitGlobalDaily, 9},
+		{"count per hour exceeded", Config{MaxCountPerHour: 1}, 1, LimitRequesterCountHour, 1},
+		{"count per hour within", Config{MaxCountPerHour: 2}, 1, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(tt.cfg)
+			c.now = func() time.Time { return now }
+
+			err := db.Transaction(func(tx *gorm.DB) error {
+				return c.Check(tx, &model.Withdrawal{Amount: decimal.NewFromInt(tt.amount), CreatedBy: 1, ToAddress: alice})
+			})
+			var exceededErr *ExceededError
+			if tt.limit == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &exceededErr) {
+				t.Fatalf("expected %s exceeded, got %v", tt.limit, err)
+			}
+			if exceededErr.Limit != tt.limit || !exceededErr.Used.Equal(decimal.NewFromInt(tt.used)) {
+				t.Fatalf("unexpected error: %+v", exceededErr)
+			}
+		})
+	}
+
+	// 执行时统计排除提款申请自身
+	c := NewChecker(Config{PerRequester24h: decimal.NewFromInt(6)})
+	c.now = func() time.Time { return now }
+	if err := c.Check(db, &existing[0]); err != nil {
+		t.Fatalf("withdrawal should not count against itself: %v", err)
+	}
+}
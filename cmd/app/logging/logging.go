@@ -0,0 +1,213 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"task/cmd/app/requestid"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 日志格式
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Redacted 脱敏后的值
+const Redacted = "[REDACTED]"
+
+// Config 日志配置
+type Config struct {
+	Level  slog.Level // TASK_LOG_LEVEL，debug、info、warn 或 error，默认 info
+	Format string     // TASK_LOG_FORMAT，json 或 text，默认 json
+}
+
+// ParseLevel 解析日志级别，如 debug、info、warn、error
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(s))
+	return level, err
+}
+
+// New 创建 Logger
+// 自动附加 context 中的请求 ID 和 With 添加的字段，并对密钥等敏感字段脱敏。
+func New(w io.Writer, cfg Config) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: cfg.Level, ReplaceAttr: redact}
+	var handler slog.Handler
+	if cfg.Format == FormatText {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// Setup 设置默认 Logger，标准库 log 的输出也会转到该 Logger
+func Setup(cfg Config) {
+	slog.SetDefault(New(os.Stderr, cfg))
+}
+
+// Fatal 打印错误日志并退出
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+type fieldsKey struct{}
+
+// With 返回附加了日志字段的 context，之后使用该 context 打印的日志都带有这些字段
+// 用于在请求、状态机和链上调用之间传递 withdrawal_id 等关联字段，同名字段以后添加的为准。
+func With(ctx context.Context, args ...any) context.Context {
+	added := slog.Group("", args...).Value.Group()
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(fields)+len(added))
+	for _, f := range fields {
+		if !hasKey(added, f.Key) {
+			merged = append(merged, f)
+		}
+	}
+	merged = append(merged, added...)
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+func hasKey(attrs []slog.Attr, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// contextHandler 从 context 中读取请求 ID、trace ID 和 With 添加的字段
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id := requestid.From(ctx); id != "" {
+			r.AddAttrs(slog.String("request_id", id))
+		}
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+		}
+		if fields, ok := ctx.Value(fieldsKey{}).([]slog.Attr); ok {
+			r.AddAttrs(fields...)
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// sensitiveKeys 字段名包含这些关键字时整体脱敏
+var sensitiveKeys = []string{"private_key", "privatekey", "secret", "password", "token", "authorization", "mnemonic", "api_key", "raw_tx"}
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// RedactValue 登记需要脱敏的值，如私钥、HMAC 密钥
+// 任何字段（包括 msg 和 err）中出现这些值都会被替换，避免通过错误信息等间接泄露。
+func RedactValue(values ...string) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		secrets = append(secrets, v)
+		// 十六进制密钥不带 0x 前缀时同样脱敏
+		if trimmed := strings.TrimPrefix(v, "0x"); trimmed != v && trimmed != "" {
+			secrets = append(secrets, trimmed)
+		}
+	}
+}
+
+// redact 按字段名和已登记的值脱敏
+func redact(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, k := range sensitiveKeys {
+		if strings.Contains(key, k) {
+			return slog.String(a.Key, Redacted)
+		}
+	}
+
+	var s string
+	switch a.Value.Kind() {
+	case slog.KindString:
+		s = a.Value.String()
+	case slog.KindAny:
+		v := a.Value.Any()
+		if err, ok := v.(error); ok {
+			s = err.Error()
+		} else if str, ok := v.(fmt.Stringer); ok {
+			s = str.String()
+		} else {
+			return a
+		}
+	default:
+		return a
+	}
+	if replaced, ok := redactString(s); ok {
+		return slog.String(a.Key, replaced)
+	}
+	return a
+}
+
+func redactString(s string) (string, bool) {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	changed := false
+	for _, secret := range secrets {
+		if strings.Contains(s, secret) {
+			s = strings.ReplaceAll(s, secret, Redacted)
+			changed = true
+		}
+	}
+	return s, changed
+}
+
+// Middleware 每个请求结束后打印一条访问日志，需要在 requestid.Middleware 之后注册
+// 5xx 为 error 级别，4xx 为 warn 级别，其余为 info 级别。
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		slog.Log(c.Request.Context(), level, "http request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"latency_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"task/cmd/app/requestid"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+	line := map[string]interface{}{}
+	err := json.Unmarshal(buf.Bytes(), &line)
+	if err != nil {
+		t.Fatalf("invalid json log %q: %v", buf.String(), err)
+	}
+	buf.Reset()
+	return line
+}
+
+func TestContextFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := New(buf, Config{Level: slog.LevelInfo})
+
+	ctx := requestid.With(context.Background(), "req-1")
+	ctx = With(ctx, "withdrawal_id", 7)
+	ctx = With(ctx, "withdrawal_id", 8, "attempt", 1)
+	logger.InfoContext(ctx, "send transaction failed", "tx_hash", "0xabc", "nonce", 3)
+
+	line := decode(t, buf)
+	for key, want := range map[string]interface{}{
+		"msg":           "send transaction failed",
+		"request_id":    "req-1",
+		"withdrawal_id": float64(8),
+		"attempt":       float64(1),
+		"tx_hash":       "0xabc",
+		"nonce":         float64(3),
+	} {
+		if line[key] != want {
+			t.Fatalf("%s: expected %v, got %v in %v", key, want, line[key], line)
+		}
+	}
+
+	// 低于配置级别的日志不输出
+	logger.DebugContext(ctx, "gas price")
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected debug log: %s", buf.String())
+	}
+}
+
+func TestRedact(t *testing.T) {
+	key := "0x" + strings.Repeat("ab", 32)
+	RedactValue(key)
+
+	buf := &bytes.Buffer{}
+	logger := New(buf, Config{Level: slog.LevelInfo})
+	logger.Info("sign failed: "+key[2:],
+		"private_key", "anything",
+		"hmac_secret", "s3cret",
+		"err", errors.New("bad key "+key),
+		"tx_hash", "0x01",
+	)
+
+	line := decode(t, buf)
+	for _, field := range []string{"private_key", "hmac_secret"} {
+		if line[field] != Redacted {
+			t.Fatalf("%s not redacted: %v", field, line)
+		}
+	}
+	if line["msg"] != "sign failed: "+Redacted || line["err"] != "bad key "+Redacted {
+		t.Fatalf("key material not redacted: %v", line)
+	}
+	if line["tx_hash"] != "0x01" {
+		t.Fatalf("unexpected tx_hash: %v", line)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("debug")
+	if err != nil || level != slog.LevelDebug {
+		t.Fatalf("expected debug, got %v, err=%v", level, err)
+	}
+	if _, err = ParseLevel("verbose"); err == nil {
+		t.Fatal("expected error for unknown level")
+	}
+}
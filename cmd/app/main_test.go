@@ -1,21 +1,112 @@
 package main
 
 import (
-	"log"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"task/cmd/app/addressbook"
+	"task/cmd/app/apierr"
+	"task/cmd/app/auth"
 	"task/cmd/app/eth"
+	"task/cmd/app/events"
+	"task/cmd/app/ledger"
+	"task/cmd/app/limits"
+	"task/cmd/app/metrics"
+	"task/cmd/app/migrate"
 	"task/cmd/app/model"
+	"task/cmd/app/outbox"
+	"task/cmd/app/repository"
 
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/shopspring/decimal"
+	"github.com/umbracle/ethgo"
 	"gorm.io/gorm"
 )
 
+// fakeEthClient 不连接链，记录签名的交易数，receipt 总是成功
+type fakeEthClient struct {
+	sends int64
+}
+
+func (f *fakeEthClient) SignTransaction(_ context.Context, _ string, value *big.Int) (*eth.SignedTransaction, error) {
+	if value == nil || value.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid value: %v", value)
+	}
+	n := atomic.AddInt64(&f.sends, 1)
+	var hash ethgo.Hash
+	binary.BigEndian.PutUint64(hash[24:], uint64(n))
+	return &eth.SignedTransaction{
+		Hash:     hash,
+		Raw:      hash[:],
+		Nonce:    uint64(n),
+		From:     eth.From,
+		GasLimit: 21010,
+		GasPrice: 2_000_000_000,
+	}, nil
+}
+
+func (f *fakeEthClient) SendRawTransactionContext(_ context.Context, data []byte) (ethgo.Hash, error) {
+	return ethgo.BytesToHash(data), nil
+}
+
+func (f *fakeEthClient) GetTransactionReceiptContext(context.Context, ethgo.Hash) (*ethgo.Receipt, error) {
+	return &ethgo.Receipt{Status: 1, GasUsed: 21000}, nil
+}
+
+func (f *fakeEthClient) PrintBalance() {}
+
+// headerAuthenticator 测试用，从 X-Test-User 请求头读取用户 ID，角色为 admin
+type headerAuthenticator struct{}
+
+func (headerAuthenticator) Authenticate(r *http.Request) (*auth.Identity, error) {
+	userID, err := strconv.ParseUint(r.Header.Get("X-Test-User"), 10, 64)
+	if err != nil {
+		return nil, auth.ErrNoCredentials
+	}
+	return &auth.Identity{UserID: userID, Method: "test", Roles: []auth.Role{auth.RoleAdmin}}, nil
+}
+
+// openTestDB 连接 TASK_TEST_DSN 指定的 postgres，未设置时使用临时目录中的 SQLite，并执行迁移
+func openTestDB(t *testing.T) *gorm.DB {
+	driver, dsn := model.DriverPostgres, os.Getenv("TASK_TEST_DSN")
+	if dsn == "" {
+		driver, dsn = model.DriverSQLite, filepath.Join(t.TempDir(), "test.db")
+	}
+	db, err := model.Open(driver, dsn)
+	if err != nil {
+		t.Fatalf("open db failed: err=%v", err)
+	}
+	migrator, err := migrate.New(db)
+	if err == nil {
+		_, err = migrator.Up(context.Background())
+	}
+	if err != nil {
+		t.Fatalf("migrate db failed: err=%v", err)
+	}
+	return db
+}
+
 func initTestData(db *gorm.DB) *model.Withdrawal {
 	withdrawal := model.Withdrawal{
-		Amount: decimal.NewFromFloat(1),
-		TxHash: "",
-		Status: 0,
+		Amount:     decimal.NewFromFloat(1),
+		AmountBase: decimal.New(1, 18),
+		TxHash:     "",
+		Status:     0,
 	}
 	err := db.Create(&withdrawal).Error
 	if err != nil {
@@ -42,19 +133,29 @@ func initTestData(db *gorm.DB) *model.Withdrawal {
 }
 
 func TestStateMachine(t *testing.T) {
-	db := model.Init()
-	client := eth.Init()
+	db := openTestDB(t)
+	client := &fakeEthClient{}
 	testData := initTestData(db)
+	defer cleanup(db, testData)
+
+	executed := testutil.ToFloat64(metrics.WithdrawalsExecuted.WithLabelValues("success"))
+	s := NewStateMachine(testData, client, outbox.NewRelay(db, client), db)
+	err := s.Execute(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(metrics.WithdrawalsExecuted.WithLabelValues("success")); got != executed+1 {
+		t.Fatalf("expected executed counter %v, got %v", executed+1, got)
+	}
 
-	s := NewStateMachine(testData, client, db)
-	log.Printf("----------before execute")
-	client.PrintBalance()
-	log.Printf("----------before execute\n\n\n")
-	s.Execute()
-	log.Printf("----------after execute")
-	client.PrintBalance()
-	log.Printf("----------after execute\n\n\n")
-	// cleanup(db, testData)
+	var withdrawal model.Withdrawal
+	err = db.First(&withdrawal, testData.ID).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withdrawal.Status != uint64(model.StateSuccess) || withdrawal.TxHash == "" {
+		t.Fatalf("unexpected withdrawal: %+v", withdrawal)
+	}
 }
 
 func cleanup(db *gorm.DB, testData *model.Withdrawal) {
@@ -67,4 +168,445 @@ func cleanup(db *gorm.DB, testData *model.Withdrawal) {
 	if err != nil {
 		panic(err)
 	}
+
+	err = db.Delete(&model.Outbox{}, "withdrawal_id = ?", testData.ID).Error
+	if err != nil {
+		panic(err)
+	}
+}
+
+// 并发的最终审批和执行请求只能上链一次
+func TestConcurrentApproveAndExecute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := openTestDB(t)
+	client := &fakeEthClient{}
+	r := newRouter(&dependencies{
+		db:             db,
+		client:         client,
+		relay:          outbox.NewRelay(db, client),
+		authenticators: []auth.Authenticator{headerAuthenticator{}},
+		limits:         limits.NewChecker(limits.Config{}),
+		addresses:      addressbook.New(db, addressbook.Config{}),
+		withdrawals:    repository.New(db),
+	})
+
+	withdrawal := model.Withdrawal{Amount: decimal.NewFromInt(1), AmountBase: decimal.New(1, 18)}
+	if err := db.Create(&withdrawal).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(db, &withdrawal)
+	err := db.Create(&model.WithdrawalConfirmation{
+		WithdrawalID: uint64(withdrawal.ID),
+		ManagerID:    1,
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(path string, userID int) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-User", strconv.Itoa(userID))
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(managerID int) {
+			defer wg.Done()
+			do(fmt.Sprintf("/withdrawal/approve/%d", withdrawal.ID), managerID)
+		}(i + 2)
+		go func() {
+			defer wg.Done()
+			do(fmt.Sprintf("/withdrawal/execute/%d", withdrawal.ID), 100)
+		}()
+	}
+	wg.Wait()
+
+	if sends := atomic.LoadInt64(&client.sends); sends != 1 {
+		t.Fatalf("transaction sent %d times, want 1", sends)
+	}
+
+	err = db.First(&withdrawal, withdrawal.ID).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withdrawal.Status != uint64(model.StateSuccess) || withdrawal.TxHash == "" {
+		t.Fatalf("unexpected withdrawal: %+v", withdrawal)
+	}
+}
+
+// 重复审批是幂等的；撤销审批后审批数重新计算，可以再次审批
+func TestApproveIdempotentAndRevoke(t *testing.T) {
+	db := openTestDB(t)
+	svc := newWithdrawalService(&dependencies{
+		db:          db,
+		limits:      limits.NewChecker(limits.Config{}),
+		addresses:   addressbook.New(db, addressbook.Config{}),
+		withdrawals: repository.New(db),
+	})
+	ctx := context.Background()
+	manager := &auth.Identity{UserID: 2, Roles: []auth.Role{auth.RoleApprover}}
+
+	withdrawal := model.Withdrawal{Amount: decimal.NewFromInt(1), AmountBase: decimal.New(1, 18), CreatedBy: 1, RequiredApprovals: 3}
+	if err := db.Create(&withdrawal).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(db, &withdrawal)
+
+	for i, already := range []bool{false, true} {
+		result, err := svc.Approve(ctx, manager, uint64(withdrawal.ID))
+		if err != nil {
+			t.Fatalf("approve %d failed: err=%v", i, err)
+		}
+		if result.AlreadyApproved != already || result.Approvals != 1 || result.Executed {
+			t.Fatalf("unexpected approve result %d: %+v", i, result)
+		}
+	}
+
+	result, err := svc.RevokeApproval(ctx, manager, uint64(withdrawal.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Approvals != 0 {
+		t.Fatalf("expected 0 approvals, got %d", result.Approvals)
+	}
+
+	_, err = svc.RevokeApproval(ctx, manager, uint64(withdrawal.ID))
+	var apiErr *apierr.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != apierr.CodeApprovalNotFound {
+		t.Fatalf("expected APPROVAL_NOT_FOUND, got %v", err)
+	}
+
+	result, err = svc.Approve(ctx, manager, uint64(withdrawal.ID))
+	if err != nil || result.AlreadyApproved || result.Approvals != 1 {
+		t.Fatalf("unexpected approve result: %+v, err=%v", result, err)
+	}
+}
+
+// 通过 HTTP 接口创建、审批并自动执行提款申请
+func TestWithdrawalAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := openTestDB(t)
+	client := &fakeEthClient{}
+	r := newRouter(&dependencies{
+		db:             db,
+		client:         client,
+		relay:          outbox.NewRelay(db, client),
+		authenticators: []auth.Authenticator{headerAuthenticator{}},
+		limits:         limits.NewChecker(limits.Config{}),
+		addresses:      addressbook.New(db, addressbook.Config{}),
+		withdrawals:    repository.New(db),
+	})
+
+	do := func(method, path string, userID int, body string) map[string]interface{} {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-User", strconv.Itoa(userID))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: status=%d, body=%s", method, path, w.Code, w.Body.String())
+		}
+		resp := map[string]interface{}{}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// 不能精确转换为 wei 或超出上限的金额被拒绝
+	for _, amount := range []string{"0.0000000000000000001", "1e30"} {
+		req := httptest.NewRequest(http.MethodPost, "/withdrawal/create", strings.NewReader(`{"amount": "`+amount+`"}`))
+		req.Header.Set("X-Test-User", "1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), string(apierr.CodeInvalidAmount)) {
+			t.Fatalf("amount %s: status=%d, body=%s", amount, w.Code, w.Body.String())
+		}
+	}
+
+	resp := do(http.MethodPost, "/withdrawal/create", 1, `{"amount": "1.5"}`)
+	id := int(resp["request_id"].(float64))
+
+	// 默认收款地址不在白名单中，需要额外审批
+	for managerID := 2; managerID < 4; managerID++ {
+		resp = do(http.MethodPost, fmt.Sprintf("/withdrawal/approve/%d", id), managerID, "{}")
+		if _, ok := resp["tx_hash"]; ok {
+			t.Fatalf("executed before enough approvals: %v", resp)
+		}
+	}
+	resp = do(http.MethodPost, fmt.Sprintf("/withdrawal/approve/%d", id), 4, "{}")
+	if resp["state"] != model.StateSuccess.String() {
+		t.Fatalf("expected success, got %v", resp)
+	}
+
+	resp = do(http.MethodGet, fmt.Sprintf("/withdrawal/status/%d", id), 1, "")
+	withdrawals := resp["withdrawals"].([]interface{})
+	if len(withdrawals) != 1 || withdrawals[0].(map[string]interface{})["amount"] != "1.5" ||
+		withdrawals[0].(map[string]interface{})["amount_base"] != "1500000000000000000" {
+		t.Fatalf("unexpected withdrawals: %v", withdrawals)
+	}
+	// 手续费 21000 * 2 gwei
+	if w := withdrawals[0].(map[string]interface{}); w["gas_used"] != float64(21000) || w["fee_wei"] != "42000000000000" {
+		t.Fatalf("unexpected gas: %v", w)
+	}
+	resp = do(http.MethodGet, "/report/gas-fees", 1, "")
+	if total := resp["total"].(map[string]interface{}); total["transactions"].(float64) < 1 {
+		t.Fatalf("unexpected report: %v", resp)
+	}
+
+	// 上链成功后预留已结算
+	var settled int64
+	db.Model(&model.JournalEntry{}).Where("withdrawal_id = ? AND kind = ?", id, ledger.KindSettle).Count(&settled)
+	held, err := ledger.Held(db, &model.Withdrawal{ID: uint(id), CreatedBy: 1})
+	if err != nil || settled != 1 || !held.IsZero() {
+		t.Fatalf("expected settled withdrawal, got settled=%d, held=%s, err=%v", settled, held, err)
+	}
+	do(http.MethodGet, "/ledger/accounts/user:1:available", 1, "")
+}
+
+// 配置的重试策略传给状态机，未配置时使用默认策略
+func TestStateMachineOptions(t *testing.T) {
+	policy := ConstantBackoff{Interval: time.Millisecond, MaxRetries: 1}
+	db := openTestDB(t)
+	svc := newWithdrawalService(&dependencies{sendRetry: policy})
+	sm := NewStateMachine(&model.Withdrawal{}, nil, nil, db, svc.stateMachineOptions()...)
+	if sm.sendPolicy != policy {
+		t.Fatalf("unexpected send policy: %+v", sm.sendPolicy)
+	}
+	if sm.receiptPolicy != DefaultReceiptRetryPolicy() {
+		t.Fatalf("unexpected receipt policy: %+v", sm.receiptPolicy)
+	}
+}
+
+// receiptHookClient 查询 receipt 时调用 onReceipt，返回 nil 表示还没有上链
+type receiptHookClient struct {
+	fakeEthClient
+	onReceipt func(ctx context.Context) *ethgo.Receipt
+}
+
+func (f *receiptHookClient) GetTransactionReceiptContext(ctx context.Context, _ ethgo.Hash) (*ethgo.Receipt, error) {
+	return f.onReceipt(ctx), nil
+}
+
+// newTestService 使用 client 执行提款的 WithdrawalService，不限额、不校验地址
+func newTestService(db *gorm.DB, client EthClient) *WithdrawalService {
+	return newWithdrawalService(&dependencies{
+		db:          db,
+		client:      client,
+		relay:       outbox.NewRelay(db, client),
+		limits:      limits.NewChecker(limits.Config{}),
+		addresses:   addressbook.New(db, addressbook.Config{}),
+		withdrawals: repository.New(db),
+	})
+}
+
+// 查询 receipt 期间不持有事务和行锁，其他请求可以锁定同一个提款申请
+func TestExecuteUnlockedDuringReceipt(t *testing.T) {
+	db := openTestDB(t)
+	testData := initTestData(db)
+	defer cleanup(db, testData)
+	// 收款地址不在白名单，需要额外一个审批
+	err := db.Create(&model.WithdrawalConfirmation{WithdrawalID: uint64(testData.ID), ManagerID: 3}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lockErr error
+	client := &receiptHookClient{onReceipt: func(context.Context) *ethgo.Receipt {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		lockErr = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			_, err := repository.New(tx).Lock(tx, uint64(testData.ID))
+			return err
+		})
+		return &ethgo.Receipt{Status: 1, GasUsed: 21000}
+	}}
+
+	identity := &auth.Identity{UserID: 100, Roles: []auth.Role{auth.RoleAdmin}}
+	withdrawal, err := newTestService(db, client).Execute(context.Background(), identity, uint64(testData.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lockErr != nil {
+		t.Fatalf("withdrawal locked during receipt polling: %v", lockErr)
+	}
+	if withdrawal.Status != uint64(model.StateSuccess) {
+		t.Fatalf("unexpected withdrawal: %+v", withdrawal)
+	}
+}
+
+// 查询 receipt 期间请求被取消，已保存的 tx hash 和状态保持不变
+func TestExecuteCanceledDuringReceipt(t *testing.T) {
+	db := openTestDB(t)
+	testData := initTestData(db)
+	defer cleanup(db, testData)
+	err := db.Create(&model.WithdrawalConfirmation{WithdrawalID: uint64(testData.ID), ManagerID: 3}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := &receiptHookClient{onReceipt: func(context.Context) *ethgo.Receipt {
+		cancel()
+		return nil
+	}}
+
+	identity := &auth.Identity{UserID: 100, Roles: []auth.Role{auth.RoleAdmin}}
+	_, err = newTestService(db, client).Execute(ctx, identity, uint64(testData.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var withdrawal model.Withdrawal
+	if err = db.First(&withdrawal, testData.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if withdrawal.TxHash == "" || withdrawal.Status != uint64(model.StatePending) {
+		t.Fatalf("tx hash and status not saved: %+v", withdrawal)
+	}
+	entry, err := outbox.NewRelay(db, client).Latest(uint64(testData.ID))
+	if err != nil || entry == nil || entry.TxHash != withdrawal.TxHash {
+		t.Fatalf("outbox entry not saved: entry=%+v, err=%v", entry, err)
+	}
+	held, err := ledger.Held(db, &withdrawal)
+	if err != nil || !held.Equal(withdrawal.Amount) {
+		t.Fatalf("reservation not saved: held=%s, err=%v", held, err)
+	}
+}
+
+// 执行时校验限额加的锁在状态机开始前释放，查询 receipt 期间其他请求可以创建提款申请
+// 设置 TASK_TEST_DSN 时覆盖 postgres 的 advisory lock，否则覆盖 SQLite 的写锁。
+func TestLimitLocksReleasedDuringExecution(t *testing.T) {
+	db := openTestDB(t)
+	testData := initTestData(db)
+	defer cleanup(db, testData)
+	err := db.Create(&model.WithdrawalConfirmation{WithdrawalID: uint64(testData.ID), ManagerID: 3}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := newTestService(db, nil)
+	svc.d.limits = limits.NewChecker(limits.Config{
+		PerRequester24h:   decimal.NewFromInt(1_000_000_000),
+		PerDestination24h: decimal.NewFromInt(1_000_000_000),
+		GlobalDaily:       decimal.NewFromInt(1_000_000_000),
+	})
+
+	var created *model.Withdrawal
+	var createErr error
+	client := &receiptHookClient{onReceipt: func(context.Context) *ethgo.Receipt {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		created, createErr = svc.Create(ctx, &auth.Identity{UserID: 100}, &WithdrawalRequest{Amount: "1"})
+		return &ethgo.Receipt{Status: 1, GasUsed: 21000}
+	}}
+	svc.d.client = client
+	svc.d.relay = outbox.NewRelay(db, client)
+
+	identity := &auth.Identity{UserID: 100, Roles: []auth.Role{auth.RoleAdmin}}
+	_, err = svc.Execute(context.Background(), identity, uint64(testData.ID))
+	if created != nil {
+		defer cleanup(db, created)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if createErr != nil {
+		t.Fatalf("create blocked by execution: %v", createErr)
+	}
+}
+
+// 提交失败时返回 INTERNAL_ERROR，而不是调用方传入的结果
+func TestCommitError(t *testing.T) {
+	db := openTestDB(t)
+	tx := db.Begin()
+	if err := commit(tx, nil); err != nil {
+		t.Fatal(err)
+	}
+	err := commit(tx, apierr.New(apierr.CodeLimitExceeded, "limit exceeded"))
+	if code := apierr.From(err).Code; code != apierr.CodeInternal {
+		t.Fatalf("expected %s, got %v", apierr.CodeInternal, err)
+	}
+}
+
+// 并发创建的提款申请合计不超过限额
+func TestConcurrentCreateLimits(t *testing.T) {
+	db := openTestDB(t)
+	svc := newWithdrawalService(&dependencies{
+		db:          db,
+		limits:      limits.NewChecker(limits.Config{PerRequester24h: decimal.NewFromInt(3)}),
+		addresses:   addressbook.New(db, addressbook.Config{}),
+		withdrawals: repository.New(db),
+	})
+	requester := &auth.Identity{UserID: 4242, Roles: []auth.Role{auth.RoleRequester}}
+	t.Cleanup(func() {
+		db.Delete(&model.Withdrawal{}, "created_by = ?", requester.UserID)
+	})
+
+	var created, exceeded int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.Create(context.Background(), requester, &WithdrawalRequest{Amount: "1"})
+			switch {
+			case err == nil:
+				atomic.AddInt64(&created, 1)
+			case apierr.From(err).Code == apierr.CodeLimitExceeded:
+				atomic.AddInt64(&exceeded, 1)
+			default:
+				t.Errorf("create failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if created != 3 || exceeded != 7 {
+		t.Fatalf("created=%d, exceeded=%d, want 3 and 7", created, exceeded)
+	}
+}
+
+// 客户端收到 ID 较大但较早提交的事件后重连，仍然能收到 ID 较小、较晚提交的事件
+func TestWatchEventsResume(t *testing.T) {
+	db := openTestDB(t)
+	const withdrawalID = 9191
+	t.Cleanup(func() {
+		db.Delete(&model.WithdrawalEvent{}, "withdrawal_id = ?", withdrawalID)
+	})
+
+	early := &model.WithdrawalEvent{WithdrawalID: withdrawalID, Type: string(events.TypeCreated)}
+	late := &model.WithdrawalEvent{WithdrawalID: withdrawalID, Type: string(events.TypeApproved)}
+	for _, e := range []*model.WithdrawalEvent{late, early} {
+		if err := db.Create(e).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// late 的 ID 较小，但在 early 之后提交
+	var maxSeq uint64
+	if err := db.Model(&model.WithdrawalEvent{}).Select("COALESCE(MAX(seq), 0)").Scan(&maxSeq).Error; err != nil {
+		t.Fatal(err)
+	}
+	early.Seq, late.Seq = maxSeq+1, maxSeq+2
+	for _, e := range []*model.WithdrawalEvent{early, late} {
+		if err := db.Model(e).Update("seq", e.Seq).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	var got []uint
+	send := func(e *model.WithdrawalEvent) error {
+		got = append(got, e.ID)
+		return nil
+	}
+	_ = watchEvents(ctx, db, events.NewHub(db), withdrawalID, uint64(early.ID), send, func() {}, nil)
+	if len(got) != 1 || got[0] != late.ID {
+		t.Fatalf("expected event %d after resume, got %v", late.ID, got)
+	}
 }
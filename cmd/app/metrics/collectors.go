@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"log/slog"
+	"math/big"
+
+	"task/cmd/app/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+var (
+	withdrawalsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "withdrawals"),
+		"Withdrawals by state.",
+		[]string{"state"}, nil,
+	)
+	walletBalanceDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "hot_wallet_balance_eth"),
+		"Hot wallet balance in ETH.",
+		[]string{"address"}, nil,
+	)
+)
+
+// StateCollector 抓取时查询各状态的提款申请数
+type StateCollector struct {
+	db *gorm.DB
+}
+
+// NewStateCollector 创建按状态统计提款申请数的 Collector
+func NewStateCollector(db *gorm.DB) *StateCollector {
+	return &StateCollector{db: db}
+}
+
+func (c *StateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- withdrawalsDesc
+}
+
+// Collect 每个状态都输出，没有提款申请的状态为 0
+func (c *StateCollector) Collect(ch chan<- prometheus.Metric) {
+	var rows []struct {
+		Status uint64
+		Count  int64
+	}
+	err := c.db.Model(&model.Withdrawal{}).
+		Select("status, count(*) AS count").
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		slog.Error("count withdrawals by state failed", "err", err)
+		ch <- prometheus.NewInvalidMetric(withdrawalsDesc, err)
+		return
+	}
+
+	counts := make(map[model.WithdrawalState]int64)
+	for _, row := range rows {
+		counts[model.WithdrawalState(row.Status)] += row.Count
+	}
+	for state := model.StateUnchained; state <= model.StateExpired; state++ {
+		ch <- prometheus.MustNewConstMetric(withdrawalsDesc, prometheus.GaugeValue, float64(counts[state]), state.String())
+	}
+}
+
+// BalanceFunc 查询地址余额，单位 wei
+type BalanceFunc func() (*big.Int, error)
+
+// BalanceCollector 抓取时查询热钱包余额
+type BalanceCollector struct {
+	address string
+	balance BalanceFunc
+}
+
+// NewBalanceCollector 创建热钱包余额的 Collector
+func NewBalanceCollector(address string, balance BalanceFunc) *BalanceCollector {
+	return &BalanceCollector{address: address, balance: balance}
+}
+
+func (c *BalanceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- walletBalanceDesc
+}
+
+func (c *BalanceCollector) Collect(ch chan<- prometheus.Metric) {
+	balance, err := c.balance()
+	if err != nil {
+		slog.Error("get hot wallet balance failed", "address", c.address, "err", err)
+		ch <- prometheus.NewInvalidMetric(walletBalanceDesc, err)
+		return
+	}
+	eth, _ := new(big.Float).Quo(new(big.Float).SetInt(balance), big.NewFloat(1e18)).Float64()
+	ch <- prometheus.MustNewConstMetric(walletBalanceDesc, prometheus.GaugeValue, eth, c.address)
+}
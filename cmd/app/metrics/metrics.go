@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "task"
+
+// 提款申请
+var (
+	// WithdrawalsCreated 创建提款申请次数，outcome 为 created 或错误码
+	WithdrawalsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "withdrawals_created_total",
+		Help:      "Withdrawal create requests by outcome.",
+	}, []string{"outcome"})
+
+	// WithdrawalsApproved 审批次数，outcome 为 approved、already_approved、executed 或错误码
+	WithdrawalsApproved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "withdrawals_approved_total",
+		Help:      "Withdrawal approvals by outcome.",
+	}, []string{"outcome"})
+
+	// WithdrawalsExecuted 状态机执行次数，outcome 为结束时的状态，或 canceled、aborted
+	WithdrawalsExecuted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "withdrawals_executed_total",
+		Help:      "Withdrawal state machine executions by outcome.",
+	}, []string{"outcome"})
+
+	// TimeToApproval 从创建到达到所需审批数的时间
+	TimeToApproval = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "withdrawal_time_to_approval_seconds",
+		Help:      "Time from withdrawal creation to reaching the required approvals.",
+		Buckets:   []float64{60, 300, 900, 1800, 3600, 4 * 3600, 12 * 3600, 24 * 3600, 3 * 24 * 3600, 7 * 24 * 3600},
+	})
+
+	// TimeToMined 从创建到上链成功的时间
+	TimeToMined = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "withdrawal_time_to_mined_seconds",
+		Help:      "Time from withdrawal creation to a successful receipt.",
+		Buckets:   []float64{1, 5, 15, 60, 300, 900, 3600, 4 * 3600, 24 * 3600, 7 * 24 * 3600},
+	})
+
+	// StateMachineRetries 每次执行状态机的重试次数，kind 为 send 或 receipt
+	StateMachineRetries = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "statemachine_retries",
+		Help:      "Retries per state machine execution.",
+		Buckets:   []float64{0, 1, 2, 3, 5, 8, 13, 21},
+	}, []string{"kind"})
+)
+
+// 链上调用
+var (
+	// RPCDuration JSON-RPC 调用耗时，按方法统计
+	RPCDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "eth_rpc_duration_seconds",
+		Help:      "Ethereum JSON-RPC latency by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	// RPCErrors JSON-RPC 调用失败次数，按方法统计
+	RPCErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "eth_rpc_errors_total",
+		Help:      "Ethereum JSON-RPC errors by method.",
+	}, []string{"method"})
+)
+
+// HTTP 接口
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "code"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// ObserveRPC 记录一次 JSON-RPC 调用，用法：defer metrics.ObserveRPC("GasPrice", time.Now(), &err)
+func ObserveRPC(method string, start time.Time, err *error) {
+	RPCDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil && *err != nil {
+		RPCErrors.WithLabelValues(method).Inc()
+	}
+}
+
+// Middleware 记录 HTTP 请求数和耗时
+// route 取注册的路由模板（如 /withdrawal/status/:request_id），避免按 ID 产生大量标签；未匹配的路由记为 unmatched。
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
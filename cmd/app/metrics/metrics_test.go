@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"task/cmd/app/migrate"
+	"task/cmd/app/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/gorm"
+)
+
+func openSQLite(t *testing.T) *gorm.DB {
+	db, err := model.Open(model.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.New(db)
+	if err == nil {
+		_, err = m.Up(context.Background())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestStateCollector(t *testing.T) {
+	db := openSQLite(t)
+	for _, status := range []model.WithdrawalState{model.StateUnchained, model.StateUnchained, model.StateSuccess} {
+		err := db.Create(&model.Withdrawal{Status: uint64(status)}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := `
+# HELP task_withdrawals Withdrawals by state.
+# TYPE task_withdrawals gauge
+task_withdrawals{state="exception"} 0
+task_withdrawals{state="expired"} 0
+task_withdrawals{state="failure"} 0
+task_withdrawals{state="pending"} 0
+task_withdrawals{state="rejected"} 0
+task_withdrawals{state="success"} 1
+task_withdrawals{state="unchained"} 2
+`
+	err := testutil.CollectAndCompare(NewStateCollector(db), strings.NewReader(expected))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBalanceCollector(t *testing.T) {
+	balance := NewBalanceCollector("0x01", func() (*big.Int, error) {
+		return new(big.Int).Mul(big.NewInt(15), big.NewInt(1e17)), nil
+	})
+	expected := `
+# HELP task_hot_wallet_balance_eth Hot wallet balance in ETH.
+# TYPE task_hot_wallet_balance_eth gauge
+task_hot_wallet_balance_eth{address="0x01"} 1.5
+`
+	err := testutil.CollectAndCompare(balance, strings.NewReader(expected))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 查询失败时抓取报错，而不是输出 0
+	failing := NewBalanceCollector("0x01", func() (*big.Int, error) {
+		return nil, errors.New("rpc unavailable")
+	})
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(failing)
+	if _, err = registry.Gather(); err == nil {
+		t.Fatal("expected collect error")
+	}
+}
+
+func TestObserveRPC(t *testing.T) {
+	before := testutil.ToFloat64(RPCErrors.WithLabelValues("test"))
+	err := errors.New("timeout")
+	ObserveRPC("test", time.Now(), &err)
+	var ok error
+	ObserveRPC("test", time.Now(), &ok)
+	if got := testutil.ToFloat64(RPCErrors.WithLabelValues("test")); got != before+1 {
+		t.Fatalf("expected %v errors, got %v", before+1, got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/withdrawal/status/:request_id", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/withdrawal/status/1", "/withdrawal/status/2", "/missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/withdrawal/status/:request_id", "200")); got != 2 {
+		t.Fatalf("expected 2 requests, got %v", got)
+	}
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "unmatched", "404")); got != 1 {
+		t.Fatalf("expected 1 unmatched request, got %v", got)
+	}
+}
@@ -0,0 +1,331 @@
+package migrate
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"task/cmd/app/model"
+
+	"gorm.io/gorm"
+)
+
+// files 内置的迁移文件，按数据库类型分目录，命名为 <版本号>_<名称>.up.sql 和 <版本号>_<名称>.down.sql
+// 各数据库的版本号和表结构保持一致。
+//
+//go:embed migrations/*/*.sql
+var files embed.FS
+
+// lockKey postgres advisory lock 的 key，多个副本同时启动时串行执行迁移
+const lockKey = 72_657_001
+
+// ErrSchemaMismatch 数据库表结构版本与程序不一致
+var ErrSchemaMismatch = errors.New("schema mismatch")
+
+// Migration 一个版本的迁移
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string // 升级 SQL
+	Down    string // 回滚 SQL
+}
+
+// schemaMigration 已执行的迁移
+type schemaMigration struct {
+	Version   uint64    `gorm:"primaryKey"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status 迁移状态
+type Status struct {
+	Version   uint64     `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"` // 为空表示未执行
+	Unknown   bool       `json:"unknown,omitempty"`    // 数据库中已执行，但程序中没有，说明数据库比程序新
+}
+
+// Load 读取内置的迁移，按版本号排序，driver 见 model.DriverPostgres、model.DriverSQLite
+func Load(driver string) ([]*Migration, error) {
+	return load(files, path.Join("migrations", driver))
+}
+
+func load(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	for _, entry := range entries {
+		version, name, direction, err := parseFilename(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d has different names: %s, %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// parseFilename 解析 0001_init.up.sql
+func parseFilename(filename string) (version uint64, name, direction string, err error) {
+	base := strings.TrimSuffix(filename, ".sql")
+	switch {
+	case strings.HasSuffix(base, ".up"):
+		direction = "up"
+	case strings.HasSuffix(base, ".down"):
+		direction = "down"
+	default:
+		return 0, "", "", fmt.Errorf("invalid migration filename: %s", filename)
+	}
+	base = strings.TrimSuffix(base, "."+direction)
+
+	v, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", "", fmt.Errorf("invalid migration filename: %s", filename)
+	}
+	version, err = strconv.ParseUint(v, 10, 64)
+	if err != nil || version == 0 {
+		return 0, "", "", fmt.Errorf("invalid migration version: %s", filename)
+	}
+	return version, name, direction, nil
+}
+
+// Migrator 执行迁移，记录在 schema_migrations 表
+type Migrator struct {
+	db         *gorm.DB
+	migrations []*Migration
+}
+
+// New 使用内置的迁移，按 db 的数据库类型选择
+func New(db *gorm.DB) (*Migrator, error) {
+	migrations, err := Load(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up 执行所有未执行的迁移，返回本次执行的迁移
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	var done []*Migration
+	err := m.locked(ctx, func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+		err = m.checkUnknown(applied)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			err = conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Up).Error; err != nil {
+					return err
+				}
+				return tx.Create(&schemaMigration{
+					Version:   migration.Version,
+					Name:      migration.Name,
+					AppliedAt: time.Now(),
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migrate up %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down 回滚最近执行的 steps 个迁移，返回本次回滚的迁移
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	var done []*Migration
+	err := m.locked(ctx, func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+		err = m.checkUnknown(applied)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			err = conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&schemaMigration{}, "version = ?", migration.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migrate down %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status 所有迁移的执行状态，包括数据库中有、程序中没有的版本
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	db := m.db.WithContext(ctx)
+	applied := make(map[uint64]*schemaMigration)
+	if db.Migrator().HasTable(&schemaMigration{}) {
+		var err error
+		applied, err = m.applied(db)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]*Status, 0, len(m.migrations))
+	known := make(map[uint64]bool)
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		status := &Status{Version: migration.Version, Name: migration.Name}
+		if a, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &a.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	for version, a := range applied {
+		if !known[version] {
+			statuses = append(statuses, &Status{Version: version, Name: a.Name, AppliedAt: &a.AppliedAt, Unknown: true})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// Check 校验所有迁移都已执行，且数据库中没有程序不知道的版本，否则返回 ErrSchemaMismatch
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var pending, unknown []string
+	for _, s := range statuses {
+		switch {
+		case s.Unknown:
+			unknown = append(unknown, fmt.Sprintf("%d_%s", s.Version, s.Name))
+		case s.AppliedAt == nil:
+			pending = append(pending, fmt.Sprintf("%d_%s", s.Version, s.Name))
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: database has unknown migrations %s, upgrade the server", ErrSchemaMismatch, strings.Join(unknown, ","))
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: pending migrations %s, run migrate up", ErrSchemaMismatch, strings.Join(pending, ","))
+	}
+	return nil
+}
+
+// locked 在同一个连接上持有 advisory lock 执行 fn
+// 只有 postgres 支持 advisory lock，其他数据库直接执行。
+func (m *Migrator) locked(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if conn.Dialector.Name() == model.DriverPostgres {
+			err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error
+			if err != nil {
+				return fmt.Errorf("acquire migration lock failed: %w", err)
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", lockKey)
+		}
+
+		timestamp := "timestamptz"
+		if conn.Dialector.Name() == model.DriverSQLite {
+			timestamp = "datetime"
+		}
+		err := conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    bigint PRIMARY KEY,
+    name       text NOT NULL,
+    applied_at ` + timestamp + ` NOT NULL
+)`).Error
+		if err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+// applied 已执行的迁移
+func (m *Migrator) applied(db *gorm.DB) (map[uint64]*schemaMigration, error) {
+	var rows []*schemaMigration
+	err := db.Order("version").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[uint64]*schemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// checkUnknown 数据库比程序新时拒绝执行迁移
+func (m *Migrator) checkUnknown(applied map[uint64]*schemaMigration) error {
+	known := make(map[uint64]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+	for version, a := range applied {
+		if !known[version] {
+			return fmt.Errorf("%w: database has unknown migration %d_%s", ErrSchemaMismatch, version, a.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"task/cmd/app/model"
+)
+
+func TestLoad(t *testing.T) {
+	postgres, err := Load(model.DriverPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(postgres) == 0 || postgres[0].Version != 1 || postgres[0].Name != "init" {
+		t.Fatalf("unexpected migrations: %+v", postgres)
+	}
+	// 版本号连续，避免合并分支时跳号或重复
+	for i, m := range postgres {
+		if m.Version != uint64(i+1) {
+			t.Fatalf("expected version %d, got %d_%s", i+1, m.Version, m.Name)
+		}
+	}
+
+	// 各数据库的迁移版本一致
+	sqlite, err := Load(model.DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sqlite) != len(postgres) {
+		t.Fatalf("expected %d sqlite migrations, got %d", len(postgres), len(sqlite))
+	}
+	for i := range postgres {
+		if sqlite[i].Version != postgres[i].Version || sqlite[i].Name != postgres[i].Name {
+			t.Fatalf("sqlite migration %d_%s does not match postgres %d_%s",
+				sqlite[i].Version, sqlite[i].Name, postgres[i].Version, postgres[i].Name)
+		}
+	}
+}
+
+// 在 SQLite 上执行所有迁移并回滚
+func TestMigrateSQLite(t *testing.T) {
+	db, err := model.Open(model.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := m.Check(ctx); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expected schema mismatch before migrate, got %v", err)
+	}
+	done, err := m.Up(ctx)
+	if err != nil || len(done) != len(m.migrations) {
+		t.Fatalf("migrate up: done=%d, err=%v", len(done), err)
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !db.Migrator().HasTable(&model.Withdrawal{}) {
+		t.Fatal("expected withdrawals table")
+	}
+
+	done, err = m.Down(ctx, len(m.migrations))
+	if err != nil || len(done) != len(m.migrations) {
+		t.Fatalf("migrate down: done=%d, err=%v", len(done), err)
+	}
+	if db.Migrator().HasTable(&model.Withdrawal{}) {
+		t.Fatal("expected withdrawals table to be dropped")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing down": {
+			"m/0001_init.up.sql": {Data: []byte("SELECT 1")},
+		},
+		"bad name": {
+			"m/init.up.sql":   {Data: []byte("SELECT 1")},
+			"m/init.down.sql": {Data: []byte("SELECT 1")},
+		},
+		"name mismatch": {
+			"m/0001_init.up.sql":    {Data: []byte("SELECT 1")},
+			"m/0001_other.down.sql": {Data: []byte("SELECT 1")},
+		},
+	}
+	for name, fsys := range tests {
+		if _, err := load(fsys, "m"); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParseFilename(t *testing.T) {
+	version, name, direction, err := parseFilename("0002_withdrawal_amount_numeric.down.sql")
+	if err != nil || version != 2 || name != "withdrawal_amount_numeric" || direction != "down" {
+		t.Fatalf("unexpected result: %d %s %s %v", version, name, direction, err)
+	}
+}
@@ -0,0 +1,169 @@
+-- 初始表结构，与此前 AutoMigrate 创建的表一致
+-- 使用 IF NOT EXISTS，已经由 AutoMigrate 建好表的数据库也可以直接执行。
+
+CREATE TABLE IF NOT EXISTS withdrawals (
+    id                 bigserial PRIMARY KEY,
+    created_at         timestamptz,
+    updated_at         timestamptz,
+    amount             numeric     NOT NULL,
+    tx_hash            text        NOT NULL,
+    status             bigint      NOT NULL,
+    created_by         bigint      NOT NULL DEFAULT 0,
+    to_address         text        NOT NULL DEFAULT '',
+    required_approvals bigint      NOT NULL DEFAULT 2
+);
+CREATE INDEX IF NOT EXISTS idx_withdrawals_created_by ON withdrawals (created_by);
+CREATE INDEX IF NOT EXISTS idx_withdrawals_to_address ON withdrawals (to_address);
+
+CREATE TABLE IF NOT EXISTS withdrawal_confirmations (
+    id            bigserial PRIMARY KEY,
+    created_at    timestamptz,
+    updated_at    timestamptz,
+    withdrawal_id bigint NOT NULL,
+    manager_id    bigint NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_withdrawal_manager ON withdrawal_confirmations (withdrawal_id, manager_id);
+
+CREATE TABLE IF NOT EXISTS outboxes (
+    id            bigserial PRIMARY KEY,
+    created_at    timestamptz,
+    updated_at    timestamptz,
+    withdrawal_id bigint NOT NULL,
+    tx_hash       text   NOT NULL,
+    raw_tx        bytea  NOT NULL,
+    nonce         bigint NOT NULL,
+    status        bigint NOT NULL,
+    attempts      bigint NOT NULL,
+    last_error    text   NOT NULL,
+    sent_at       timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_outboxes_withdrawal_id ON outboxes (withdrawal_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outboxes_tx_hash ON outboxes (tx_hash);
+CREATE INDEX IF NOT EXISTS idx_outboxes_status ON outboxes (status);
+
+CREATE TABLE IF NOT EXISTS withdrawal_events (
+    id            bigserial PRIMARY KEY,
+    created_at    timestamptz,
+    withdrawal_id bigint NOT NULL,
+    type          text   NOT NULL,
+    status        bigint NOT NULL,
+    tx_hash       text   NOT NULL,
+    data          text   NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_withdrawal_events_withdrawal_id ON withdrawal_events (withdrawal_id);
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id              bigserial PRIMARY KEY,
+    created_at      timestamptz,
+    updated_at      timestamptz,
+    user_id         bigint  NOT NULL,
+    url             text    NOT NULL,
+    secret          text    NOT NULL,
+    events          text    NOT NULL,
+    all_withdrawals boolean NOT NULL,
+    disabled_at     timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_user_id ON webhook_subscriptions (user_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_disabled_at ON webhook_subscriptions (disabled_at);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              bigserial PRIMARY KEY,
+    created_at      timestamptz,
+    updated_at      timestamptz,
+    subscription_id bigint      NOT NULL,
+    event_id        bigint      NOT NULL,
+    event_type      text        NOT NULL,
+    payload         text        NOT NULL,
+    attempts        bigint      NOT NULL,
+    next_attempt_at timestamptz NOT NULL,
+    last_error      text        NOT NULL,
+    last_status     bigint      NOT NULL,
+    delivered_at    timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_id ON webhook_deliveries (subscription_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_next_attempt_at ON webhook_deliveries (next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_delivered_at ON webhook_deliveries (delivered_at);
+
+CREATE TABLE IF NOT EXISTS webhook_dead_letters (
+    id              bigserial PRIMARY KEY,
+    created_at      timestamptz,
+    subscription_id bigint NOT NULL,
+    event_id        bigint NOT NULL,
+    event_type      text   NOT NULL,
+    payload         text   NOT NULL,
+    attempts        bigint NOT NULL,
+    last_error      text   NOT NULL,
+    last_status     bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_dead_letters_subscription_id ON webhook_dead_letters (subscription_id);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    user_id    bigint NOT NULL,
+    name       text   NOT NULL,
+    key_hash   text   NOT NULL,
+    revoked_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_hash ON api_keys (key_hash);
+
+CREATE TABLE IF NOT EXISTS hmac_keys (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    key_id     text   NOT NULL,
+    user_id    bigint NOT NULL,
+    name       text   NOT NULL,
+    secret     text   NOT NULL,
+    revoked_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_hmac_keys_key_id ON hmac_keys (key_id);
+CREATE INDEX IF NOT EXISTS idx_hmac_keys_user_id ON hmac_keys (user_id);
+
+CREATE TABLE IF NOT EXISTS allowed_addresses (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    address    text        NOT NULL,
+    label      text        NOT NULL,
+    active_at  timestamptz NOT NULL,
+    created_by bigint      NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_allowed_addresses_address ON allowed_addresses (address);
+
+CREATE TABLE IF NOT EXISTS blocked_addresses (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    address    text NOT NULL,
+    reason     text NOT NULL,
+    source     text NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_blocked_addresses_address ON blocked_addresses (address);
+
+CREATE TABLE IF NOT EXISTS address_audits (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    action     text   NOT NULL,
+    address    text   NOT NULL,
+    actor_id   bigint NOT NULL,
+    detail     text   NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_address_audits_address ON address_audits (address);
+
+CREATE TABLE IF NOT EXISTS users (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    name       text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    user_id    bigint NOT NULL,
+    role       text   NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_role ON user_roles (user_id, role);
@@ -0,0 +1 @@
+ALTER TABLE withdrawals ALTER COLUMN amount TYPE text USING amount::text;
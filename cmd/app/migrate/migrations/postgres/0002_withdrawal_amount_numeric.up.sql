@@ -0,0 +1,2 @@
+-- AutoMigrate 把 decimal.Decimal 建成 text，限额的 SUM(amount) 需要数值类型
+ALTER TABLE withdrawals ALTER COLUMN amount TYPE numeric USING amount::numeric;
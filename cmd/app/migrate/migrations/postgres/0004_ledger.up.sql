@@ -0,0 +1,30 @@
+-- 复式记账账本：账户、记账凭证、分录
+-- 已有的提款申请没有预留记录，上链成功时直接从可用余额扣减
+
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    name       text   NOT NULL,
+    user_id    bigint NOT NULL DEFAULT 0
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_accounts_name ON ledger_accounts (name);
+CREATE INDEX IF NOT EXISTS idx_ledger_accounts_user_id ON ledger_accounts (user_id);
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id            bigserial PRIMARY KEY,
+    created_at    timestamptz,
+    withdrawal_id bigint NOT NULL,
+    kind          text   NOT NULL,
+    memo          text   NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_journal_entries_withdrawal_id ON journal_entries (withdrawal_id);
+
+CREATE TABLE IF NOT EXISTS postings (
+    id               bigserial PRIMARY KEY,
+    created_at       timestamptz,
+    journal_entry_id bigint  NOT NULL,
+    account_id       bigint  NOT NULL,
+    amount           numeric NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_postings_journal_entry_id ON postings (journal_entry_id);
+CREATE INDEX IF NOT EXISTS idx_postings_account_id ON postings (account_id);
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_outboxes_mined_at;
+ALTER TABLE outboxes DROP COLUMN IF EXISTS mined_at;
+ALTER TABLE outboxes DROP COLUMN IF EXISTS fee_wei;
+ALTER TABLE outboxes DROP COLUMN IF EXISTS effective_gas_price;
+ALTER TABLE outboxes DROP COLUMN IF EXISTS gas_used;
+ALTER TABLE outboxes DROP COLUMN IF EXISTS gas_price;
+ALTER TABLE outboxes DROP COLUMN IF EXISTS gas_limit;
+ALTER TABLE outboxes DROP COLUMN IF EXISTS from_address;
+
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS fee_wei;
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS effective_gas_price;
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS gas_used;
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS gas_limit;
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS asset;
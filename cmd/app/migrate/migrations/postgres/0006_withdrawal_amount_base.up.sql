@@ -0,0 +1,3 @@
+-- 提款金额的最小单位（ETH 为 wei），上链使用该金额
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS amount_base numeric(78,0) NOT NULL DEFAULT 0;
+UPDATE withdrawals SET amount_base = trunc(amount * 1000000000000000000) WHERE asset = 'ETH' AND amount_base = 0;
@@ -0,0 +1,7 @@
+-- 定时对账已扫描到的区块，按发送地址记录，重启后从上次的位置继续
+
+CREATE TABLE IF NOT EXISTS reconcile_checkpoints (
+    address    text PRIMARY KEY,
+    updated_at timestamptz,
+    last_block bigint NOT NULL DEFAULT 0
+);
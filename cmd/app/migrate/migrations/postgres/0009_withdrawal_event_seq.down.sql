@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_withdrawal_events_seq;
+ALTER TABLE withdrawal_events DROP COLUMN IF EXISTS seq;
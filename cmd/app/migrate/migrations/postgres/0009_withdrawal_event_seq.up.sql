@@ -0,0 +1,6 @@
+-- 事件提交后分配的递增序号，事务提交顺序与 ID 顺序可能不同，按 seq 推送不会漏掉 ID 较小但较晚提交的事件
+-- 0 表示尚未分配；已有事件都已提交，seq 取 ID
+
+ALTER TABLE withdrawal_events ADD COLUMN IF NOT EXISTS seq bigint NOT NULL DEFAULT 0;
+UPDATE withdrawal_events SET seq = id WHERE seq = 0;
+CREATE INDEX IF NOT EXISTS idx_withdrawal_events_seq ON withdrawal_events (seq);
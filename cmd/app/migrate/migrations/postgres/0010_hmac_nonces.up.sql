@@ -0,0 +1,10 @@
+-- 已使用的 HMAC 请求 nonce，多个实例共享，重启后仍然有效，过期后删除
+
+CREATE TABLE IF NOT EXISTS hmac_nonces (
+    id         bigserial PRIMARY KEY,
+    key_id     text        NOT NULL,
+    nonce      text        NOT NULL,
+    expires_at timestamptz NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_hmac_nonces_key_nonce ON hmac_nonces (key_id, nonce);
+CREATE INDEX IF NOT EXISTS idx_hmac_nonces_expires_at ON hmac_nonces (expires_at);
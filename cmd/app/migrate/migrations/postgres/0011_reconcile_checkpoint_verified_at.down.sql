@@ -0,0 +1 @@
+ALTER TABLE reconcile_checkpoints DROP COLUMN IF EXISTS verified_at;
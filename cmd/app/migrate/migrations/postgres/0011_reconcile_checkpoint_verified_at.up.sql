@@ -0,0 +1,3 @@
+-- 上一轮对账开始的时间，之后只重新检查此后更新过的上链成功的提款申请
+
+ALTER TABLE reconcile_checkpoints ADD COLUMN IF NOT EXISTS verified_at timestamptz;
@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS address_audits;
+DROP TABLE IF EXISTS blocked_addresses;
+DROP TABLE IF EXISTS allowed_addresses;
+DROP TABLE IF EXISTS hmac_keys;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS webhook_dead_letters;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
+DROP TABLE IF EXISTS withdrawal_events;
+DROP TABLE IF EXISTS outboxes;
+DROP TABLE IF EXISTS withdrawal_confirmations;
+DROP TABLE IF EXISTS withdrawals;
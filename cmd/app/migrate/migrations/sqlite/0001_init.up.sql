@@ -0,0 +1,168 @@
+-- 初始表结构，与 postgres 的迁移保持相同的版本号和表结构
+
+CREATE TABLE IF NOT EXISTS withdrawals (
+    id                 integer PRIMARY KEY AUTOINCREMENT,
+    created_at         datetime,
+    updated_at         datetime,
+    amount             numeric  NOT NULL,
+    tx_hash            text     NOT NULL,
+    status             integer  NOT NULL,
+    created_by         integer  NOT NULL DEFAULT 0,
+    to_address         text     NOT NULL DEFAULT '',
+    required_approvals integer  NOT NULL DEFAULT 2
+);
+CREATE INDEX IF NOT EXISTS idx_withdrawals_created_by ON withdrawals (created_by);
+CREATE INDEX IF NOT EXISTS idx_withdrawals_to_address ON withdrawals (to_address);
+
+CREATE TABLE IF NOT EXISTS withdrawal_confirmations (
+    id            integer PRIMARY KEY AUTOINCREMENT,
+    created_at    datetime,
+    updated_at    datetime,
+    withdrawal_id integer  NOT NULL,
+    manager_id    integer  NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_withdrawal_manager ON withdrawal_confirmations (withdrawal_id, manager_id);
+
+CREATE TABLE IF NOT EXISTS outboxes (
+    id            integer PRIMARY KEY AUTOINCREMENT,
+    created_at    datetime,
+    updated_at    datetime,
+    withdrawal_id integer  NOT NULL,
+    tx_hash       text     NOT NULL,
+    raw_tx        blob     NOT NULL,
+    nonce         integer  NOT NULL,
+    status        integer  NOT NULL,
+    attempts      integer  NOT NULL,
+    last_error    text     NOT NULL,
+    sent_at       datetime
+);
+CREATE INDEX IF NOT EXISTS idx_outboxes_withdrawal_id ON outboxes (withdrawal_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outboxes_tx_hash ON outboxes (tx_hash);
+CREATE INDEX IF NOT EXISTS idx_outboxes_status ON outboxes (status);
+
+CREATE TABLE IF NOT EXISTS withdrawal_events (
+    id            integer PRIMARY KEY AUTOINCREMENT,
+    created_at    datetime,
+    withdrawal_id integer  NOT NULL,
+    type          text     NOT NULL,
+    status        integer  NOT NULL,
+    tx_hash       text     NOT NULL,
+    data          text     NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_withdrawal_events_withdrawal_id ON withdrawal_events (withdrawal_id);
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id              integer PRIMARY KEY AUTOINCREMENT,
+    created_at      datetime,
+    updated_at      datetime,
+    user_id         integer  NOT NULL,
+    url             text     NOT NULL,
+    secret          text     NOT NULL,
+    events          text     NOT NULL,
+    all_withdrawals boolean  NOT NULL,
+    disabled_at     datetime
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_user_id ON webhook_subscriptions (user_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_disabled_at ON webhook_subscriptions (disabled_at);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              integer PRIMARY KEY AUTOINCREMENT,
+    created_at      datetime,
+    updated_at      datetime,
+    subscription_id integer  NOT NULL,
+    event_id        integer  NOT NULL,
+    event_type      text     NOT NULL,
+    payload         text     NOT NULL,
+    attempts        integer  NOT NULL,
+    next_attempt_at datetime NOT NULL,
+    last_error      text     NOT NULL,
+    last_status     integer  NOT NULL,
+    delivered_at    datetime
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_id ON webhook_deliveries (subscription_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_next_attempt_at ON webhook_deliveries (next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_delivered_at ON webhook_deliveries (delivered_at);
+
+CREATE TABLE IF NOT EXISTS webhook_dead_letters (
+    id              integer PRIMARY KEY AUTOINCREMENT,
+    created_at      datetime,
+    subscription_id integer  NOT NULL,
+    event_id        integer  NOT NULL,
+    event_type      text     NOT NULL,
+    payload         text     NOT NULL,
+    attempts        integer  NOT NULL,
+    last_error      text     NOT NULL,
+    last_status     integer  NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_dead_letters_subscription_id ON webhook_dead_letters (subscription_id);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    user_id    integer  NOT NULL,
+    name       text     NOT NULL,
+    key_hash   text     NOT NULL,
+    revoked_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_hash ON api_keys (key_hash);
+
+CREATE TABLE IF NOT EXISTS hmac_keys (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    key_id     text     NOT NULL,
+    user_id    integer  NOT NULL,
+    name       text     NOT NULL,
+    secret     text     NOT NULL,
+    revoked_at datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_hmac_keys_key_id ON hmac_keys (key_id);
+CREATE INDEX IF NOT EXISTS idx_hmac_keys_user_id ON hmac_keys (user_id);
+
+CREATE TABLE IF NOT EXISTS allowed_addresses (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    address    text     NOT NULL,
+    label      text     NOT NULL,
+    active_at  datetime NOT NULL,
+    created_by integer  NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_allowed_addresses_address ON allowed_addresses (address);
+
+CREATE TABLE IF NOT EXISTS blocked_addresses (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    address    text     NOT NULL,
+    reason     text     NOT NULL,
+    source     text     NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_blocked_addresses_address ON blocked_addresses (address);
+
+CREATE TABLE IF NOT EXISTS address_audits (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    action     text     NOT NULL,
+    address    text     NOT NULL,
+    actor_id   integer  NOT NULL,
+    detail     text     NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_address_audits_address ON address_audits (address);
+
+CREATE TABLE IF NOT EXISTS users (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    name       text     NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    user_id    integer  NOT NULL,
+    role       text     NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_role ON user_roles (user_id, role);
@@ -0,0 +1,2 @@
+-- SQLite 的表在 0001 中已经是 numeric，保留该版本号与 postgres 一致
+SELECT 1;
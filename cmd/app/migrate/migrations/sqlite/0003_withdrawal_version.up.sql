@@ -0,0 +1,2 @@
+-- 乐观锁版本号，每次更新提款申请时加 1
+ALTER TABLE withdrawals ADD COLUMN version integer NOT NULL DEFAULT 0;
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_outboxes_mined_at;
+ALTER TABLE outboxes DROP COLUMN mined_at;
+ALTER TABLE outboxes DROP COLUMN fee_wei;
+ALTER TABLE outboxes DROP COLUMN effective_gas_price;
+ALTER TABLE outboxes DROP COLUMN gas_used;
+ALTER TABLE outboxes DROP COLUMN gas_price;
+ALTER TABLE outboxes DROP COLUMN gas_limit;
+ALTER TABLE outboxes DROP COLUMN from_address;
+
+ALTER TABLE withdrawals DROP COLUMN fee_wei;
+ALTER TABLE withdrawals DROP COLUMN effective_gas_price;
+ALTER TABLE withdrawals DROP COLUMN gas_used;
+ALTER TABLE withdrawals DROP COLUMN gas_limit;
+ALTER TABLE withdrawals DROP COLUMN asset;
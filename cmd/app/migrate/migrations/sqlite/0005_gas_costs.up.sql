@@ -0,0 +1,16 @@
+-- 记录每笔交易和每个提款申请实际的 gas 消耗和手续费，金额单位 wei
+
+ALTER TABLE withdrawals ADD COLUMN asset               text          NOT NULL DEFAULT 'ETH';
+ALTER TABLE withdrawals ADD COLUMN gas_limit           integer       NOT NULL DEFAULT 0;
+ALTER TABLE withdrawals ADD COLUMN gas_used            integer       NOT NULL DEFAULT 0;
+ALTER TABLE withdrawals ADD COLUMN effective_gas_price numeric(78,0) NOT NULL DEFAULT 0;
+ALTER TABLE withdrawals ADD COLUMN fee_wei             numeric(78,0) NOT NULL DEFAULT 0;
+
+ALTER TABLE outboxes ADD COLUMN from_address        text          NOT NULL DEFAULT '';
+ALTER TABLE outboxes ADD COLUMN gas_limit           integer       NOT NULL DEFAULT 0;
+ALTER TABLE outboxes ADD COLUMN gas_price           numeric(78,0) NOT NULL DEFAULT 0;
+ALTER TABLE outboxes ADD COLUMN gas_used            integer       NOT NULL DEFAULT 0;
+ALTER TABLE outboxes ADD COLUMN effective_gas_price numeric(78,0) NOT NULL DEFAULT 0;
+ALTER TABLE outboxes ADD COLUMN fee_wei             numeric(78,0) NOT NULL DEFAULT 0;
+ALTER TABLE outboxes ADD COLUMN mined_at            datetime;
+CREATE INDEX IF NOT EXISTS idx_outboxes_mined_at ON outboxes (mined_at);
@@ -0,0 +1,4 @@
+-- 提款金额的最小单位（ETH 为 wei），上链使用该金额
+-- SQLite 按浮点数计算，只用于本地运行和测试
+ALTER TABLE withdrawals ADD COLUMN amount_base numeric(78,0) NOT NULL DEFAULT 0;
+UPDATE withdrawals SET amount_base = amount * 1000000000000000000 WHERE asset = 'ETH' AND amount_base = 0;
@@ -0,0 +1,2 @@
+-- 创建待投递记录时请求的 W3C traceparent，投递时作为父 span 并写入请求头
+ALTER TABLE webhook_deliveries ADD COLUMN trace_parent text NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE reconcile_checkpoints DROP COLUMN verified_at;
@@ -1,8 +1,11 @@
 package model
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/glebarez/sqlite"
 	"github.com/shopspring/decimal"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -17,16 +20,49 @@ const (
 	StateSuccess                          // 上链成功
 	StateFailure                          // 上链失败
 	StateException                        // 其他异常情况
+	StateRejected                         // 已拒绝，不再执行
+	StateExpired                          // 超过有效期未上链，不再执行
 )
 
+func (s WithdrawalState) String() string {
+	switch s {
+	case StateUnchained:
+		return "unchained"
+	case StatePending:
+		return "pending"
+	case StateSuccess:
+		return "success"
+	case StateFailure:
+		return "failure"
+	case StateException:
+		return "exception"
+	case StateRejected:
+		return "rejected"
+	case StateExpired:
+		return "expired"
+	default:
+		return "unknown"
+	}
+}
+
 // Withdrawal 提款申请
 type Withdrawal struct {
-	ID        uint            `gorm:"primary_key" json:"id,omitempty"`
-	CreatedAt time.Time       `json:"created_at"`
-	UpdatedAt time.Time       `json:"updated_at"`
-	Amount    decimal.Decimal `gorm:"not null" json:"amount"`            // 提款金额
-	TxHash    string          `gorm:"not null" json:"tx_hash,omitempty"` // 交易哈希
-	Status    uint64          `gorm:"not null" json:"status,omitempty"`  // 状态 0: 未上链 1: 上链中 2: 上链成功 3: 上链失败 4: 其他异常情况
+	ID                uint            `gorm:"primary_key" json:"id,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+	Amount            decimal.Decimal `gorm:"type:numeric;not null" json:"amount"`                              // 提款金额，用于显示
+	AmountBase        decimal.Decimal `gorm:"type:numeric(78,0);not null;default:0" json:"amount_base"`         // 提款金额，最小单位（ETH 为 wei），上链使用该金额
+	TxHash            string          `gorm:"not null" json:"tx_hash,omitempty"`                                // 交易哈希
+	Status            uint64          `gorm:"not null" json:"status,omitempty"`                                 // 状态 0: 未上链 1: 上链中 2: 上链成功 3: 上链失败 4: 其他异常情况 5: 已拒绝 6: 已过期
+	CreatedBy         uint64          `gorm:"not null;default:0;index" json:"created_by"`                       // 发起人用户 ID，不能参与审批
+	ToAddress         string          `gorm:"not null;default:'';index" json:"to_address"`                      // 收款地址，为空时使用 eth.To
+	RequiredApprovals uint64          `gorm:"not null;default:2" json:"required_approvals"`                     // 执行所需审批数，收款地址不在白名单时需要额外审批
+	Asset             string          `gorm:"not null;default:'ETH'" json:"asset"`                              // 资产
+	GasLimit          uint64          `gorm:"not null;default:0" json:"gas_limit"`                              // 最近一笔上链交易的 gas limit
+	GasUsed           uint64          `gorm:"not null;default:0" json:"gas_used"`                               // 最近一笔上链交易实际使用的 gas
+	EffectiveGasPrice decimal.Decimal `gorm:"type:numeric(78,0);not null;default:0" json:"effective_gas_price"` // 最近一笔上链交易实际的 gas price，单位 wei
+	FeeWei            decimal.Decimal `gorm:"type:numeric(78,0);not null;default:0" json:"fee_wei"`             // 所有已上链交易的手续费之和，单位 wei，上链失败的交易同样消耗 gas
+	Version           uint64          `gorm:"not null;default:0" json:"version"`                                // 乐观锁版本号，每次更新加 1，见 repository.WithdrawalRepository.Update
 }
 
 // WithdrawalConfirmation 提款申请确认
@@ -38,22 +74,271 @@ type WithdrawalConfirmation struct {
 	ManagerID    uint64    `gorm:"not null;uniqueIndex:idx_withdrawal_manager" json:"manager_id,omitempty"`    // 经理 ID
 }
 
-func Init() *gorm.DB {
-	dsn := "host=task-postgres user=gorm password=gorm dbname=gorm port=5432 sslmode=disable TimeZone=Asia/Shanghai"
-	// dsn := "host=localhost user=gorm password=gorm dbname=gorm port=5432 sslmode=disable TimeZone=Asia/Shanghai"
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		panic("failed to connect database")
-	}
+// OutboxState 待广播交易状态
+type OutboxState uint8
+
+const (
+	OutboxPending OutboxState = iota // 已落库，未广播成功
+	OutboxSent                       // 已广播
+	OutboxFailed                     // 多次广播失败，放弃
+)
+
+// Outbox 待广播交易
+// 签名后的交易先与哈希一起提交到该表，再广播，保证每一笔广播过的交易都有记录，崩溃后可以安全地重新广播。
+type Outbox struct {
+	ID           uint       `gorm:"primary_key" json:"id,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	WithdrawalID uint64     `gorm:"not null;index" json:"withdrawal_id,omitempty"` // 提款申请 ID
+	TxHash       string     `gorm:"not null;uniqueIndex" json:"tx_hash"`           // 交易哈希
+	RawTx        []byte     `gorm:"not null" json:"-"`                             // RLP 编码后的签名交易
+	Nonce        uint64     `gorm:"not null" json:"nonce"`                         // 交易 nonce
+	Status       uint64     `gorm:"not null;index" json:"status"`                  // 状态 0: 未广播 1: 已广播 2: 放弃
+	Attempts     int        `gorm:"not null" json:"attempts"`                      // 广播次数
+	LastError    string     `gorm:"not null" json:"last_error,omitempty"`          // 最近一次广播失败原因
+	SentAt       *time.Time `json:"sent_at,omitempty"`                             // 广播成功时间
+
+	FromAddress       string          `gorm:"not null;default:''" json:"from_address"`                          // 发送地址
+	GasLimit          uint64          `gorm:"not null;default:0" json:"gas_limit"`                              // gas limit
+	GasPrice          decimal.Decimal `gorm:"type:numeric(78,0);not null;default:0" json:"gas_price"`           // 签名时的 gas price，单位 wei
+	GasUsed           uint64          `gorm:"not null;default:0" json:"gas_used"`                               // receipt 中实际使用的 gas，未上链为 0
+	EffectiveGasPrice decimal.Decimal `gorm:"type:numeric(78,0);not null;default:0" json:"effective_gas_price"` // 实际的 gas price，单位 wei
+	FeeWei            decimal.Decimal `gorm:"type:numeric(78,0);not null;default:0" json:"fee_wei"`             // 实际手续费 gas_used * effective_gas_price，单位 wei
+	MinedAt           *time.Time      `gorm:"index" json:"mined_at,omitempty"`                                  // 首次查询到 receipt 的时间
+}
+
+// WithdrawalEvent 提款申请事件
+// 状态机和接口产生的每一次状态变化都写入该表，与变化本身在同一个事务中提交；webhook 和 SSE 都基于该表。
+type WithdrawalEvent struct {
+	ID           uint      `gorm:"primary_key" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	WithdrawalID uint64    `gorm:"not null;index" json:"withdrawal_id"` // 提款申请 ID
+	Type         string    `gorm:"not null" json:"type"`                // 事件类型，见 events 包
+	Status       uint64    `gorm:"not null" json:"status"`              // 事件发生后的状态
+	TxHash       string    `gorm:"not null" json:"tx_hash,omitempty"`   // 事件发生后的交易哈希
+	Data         string    `gorm:"not null" json:"data,omitempty"`      // 事件附加信息，JSON
+	Seq          uint64    `gorm:"not null;default:0;index" json:"-"`   // 提交后分配的递增序号，0 表示尚未分配，见 events.Sequence
+}
+
+// WebhookSubscription webhook 订阅
+type WebhookSubscription struct {
+	ID             uint       `gorm:"primary_key" json:"id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	UserID         uint64     `gorm:"not null;index" json:"user_id"`      // 订阅人
+	URL            string     `gorm:"not null" json:"url"`                // 回调地址
+	Secret         string     `gorm:"not null" json:"-"`                  // 签名密钥
+	Events         string     `gorm:"not null" json:"events"`             // 订阅的事件类型，逗号分隔，为空表示全部
+	AllWithdrawals bool       `gorm:"not null" json:"all_withdrawals"`    // 是否接收所有提款申请的事件，否则只接收订阅人发起的
+	DisabledAt     *time.Time `gorm:"index" json:"disabled_at,omitempty"` // 取消订阅时间
+}
+
+// WebhookDelivery 待投递的 webhook
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primary_key" json:"id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	SubscriptionID uint64     `gorm:"not null;index" json:"subscription_id"` // 订阅 ID
+	EventID        uint64     `gorm:"not null" json:"event_id"`              // 事件 ID
+	EventType      string     `gorm:"not null" json:"event_type"`            // 事件类型
+	Payload        string     `gorm:"not null" json:"payload"`               // 请求体
+	Attempts       int        `gorm:"not null" json:"attempts"`              // 投递次数
+	NextAttemptAt  time.Time  `gorm:"not null;index" json:"next_attempt_at"` // 下次投递时间
+	LastError      string     `gorm:"not null" json:"last_error,omitempty"`  // 最近一次投递失败原因
+	LastStatus     int        `gorm:"not null" json:"last_status,omitempty"` // 最近一次投递的 HTTP 状态码
+	DeliveredAt    *time.Time `gorm:"index" json:"delivered_at,omitempty"`   // 投递成功时间
+	TraceParent    string     `gorm:"not null;default:''" json:"-"`          // 创建时请求的 W3C traceparent，投递时沿用
+}
+
+// WebhookDeadLetter 重试耗尽仍投递失败的 webhook，可以通过接口重新投递
+type WebhookDeadLetter struct {
+	ID             uint      `gorm:"primary_key" json:"id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	SubscriptionID uint64    `gorm:"not null;index" json:"subscription_id"` // 订阅 ID
+	EventID        uint64    `gorm:"not null" json:"event_id"`              // 事件 ID
+	EventType      string    `gorm:"not null" json:"event_type"`            // 事件类型
+	Payload        string    `gorm:"not null" json:"payload"`               // 请求体
+	Attempts       int       `gorm:"not null" json:"attempts"`              // 投递次数
+	LastError      string    `gorm:"not null" json:"last_error,omitempty"`  // 最近一次投递失败原因
+	LastStatus     int       `gorm:"not null" json:"last_status,omitempty"` // 最近一次投递的 HTTP 状态码
+}
+
+// HMACKey 服务间调用的签名密钥
+// 验签需要原始密钥，因此与 APIKey 不同，这里保存的是密钥本身，数据库访问权限需要相应收紧。
+type HMACKey struct {
+	ID        uint       `gorm:"primary_key" json:"id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	KeyID     string     `gorm:"not null;uniqueIndex" json:"key_id"` // 密钥 ID，放在 X-Key-Id 请求头
+	UserID    uint64     `gorm:"not null;index" json:"user_id"`      // 所属用户 ID
+	Name      string     `gorm:"not null" json:"name"`               // 备注
+	Secret    string     `gorm:"not null" json:"-"`                  // 签名密钥
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`               // 吊销时间
+}
+
+// HMACNonce 已使用的 HMAC 请求 nonce，(KeyID, Nonce) 唯一，过期后删除
+type HMACNonce struct {
+	ID        uint      `gorm:"primary_key" json:"id,omitempty"`
+	KeyID     string    `gorm:"not null;uniqueIndex:idx_hmac_nonces_key_nonce" json:"key_id"` // 密钥 ID
+	Nonce     string    `gorm:"not null;uniqueIndex:idx_hmac_nonces_key_nonce" json:"nonce"`  // 请求的 X-Nonce
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`                             // 过期时间，之后时间戳校验会拒绝该请求
+}
+
+// AllowedAddress 收款地址白名单
+// 新加入的地址在冷静期结束（ActiveAt）之前不视为白名单地址。
+type AllowedAddress struct {
+	ID        uint      `gorm:"primary_key" json:"id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Address   string    `gorm:"not null;uniqueIndex" json:"address"` // 地址
+	Label     string    `gorm:"not null" json:"label"`               // 标签
+	ActiveAt  time.Time `gorm:"not null" json:"active_at"`           // 冷静期结束时间
+	CreatedBy uint64    `gorm:"not null" json:"created_by"`          // 添加人
+}
+
+// BlockedAddress 收款地址黑名单
+type BlockedAddress struct {
+	ID        uint      `gorm:"primary_key" json:"id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Address   string    `gorm:"not null;uniqueIndex" json:"address"` // 地址
+	Reason    string    `gorm:"not null" json:"reason"`              // 原因
+	Source    string    `gorm:"not null" json:"source"`              // 来源：manual 或导入的文件名
+}
+
+// AddressAudit 白名单、黑名单变更记录
+type AddressAudit struct {
+	ID        uint      `gorm:"primary_key" json:"id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Action    string    `gorm:"not null" json:"action"`        // allow_add / allow_remove / block_add / block_remove
+	Address   string    `gorm:"not null;index" json:"address"` // 地址
+	ActorID   uint64    `gorm:"not null" json:"actor_id"`      // 操作人，0 表示命令行
+	Detail    string    `gorm:"not null" json:"detail"`        // 标签、原因等
+}
+
+// User 用户
+type User struct {
+	ID        uint      `gorm:"primary_key" json:"id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `gorm:"not null" json:"name"` // 名称
+}
+
+// UserRole 用户角色
+type UserRole struct {
+	ID        uint      `gorm:"primary_key" json:"id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uint64    `gorm:"not null;uniqueIndex:idx_user_role" json:"user_id"` // 用户 ID
+	Role      string    `gorm:"not null;uniqueIndex:idx_user_role" json:"role"`    // 角色
+}
+
+// APIKey 调用方 API key，只保存 SHA-256
+type APIKey struct {
+	ID        uint       `gorm:"primary_key" json:"id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	UserID    uint64     `gorm:"not null;index" json:"user_id"` // 所属用户 ID
+	Name      string     `gorm:"not null" json:"name"`          // 备注
+	KeyHash   string     `gorm:"not null;uniqueIndex" json:"-"` // key 的 SHA-256
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`          // 吊销时间
+}
+
+// LedgerAccount 账本账户，余额为该账户所有分录金额之和
+type LedgerAccount struct {
+	ID        uint      `gorm:"primary_key" json:"id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `gorm:"not null;uniqueIndex" json:"name"`                  // 账户名，见 ledger 包
+	UserID    uint64    `gorm:"not null;default:0;index" json:"user_id,omitempty"` // 所属用户，系统账户为 0
+}
+
+// JournalEntry 记账凭证，一次资金变动对应一条，所有分录金额之和为 0
+type JournalEntry struct {
+	ID           uint      `gorm:"primary_key" json:"id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	WithdrawalID uint64    `gorm:"not null;index" json:"withdrawal_id"` // 提款申请 ID
+	Kind         string    `gorm:"not null" json:"kind"`                // reserve / settle / release
+	Memo         string    `gorm:"not null" json:"memo,omitempty"`      // 说明，如释放原因
+}
+
+// Posting 分录，金额为正表示账户余额增加，为负表示减少
+type Posting struct {
+	ID             uint            `gorm:"primary_key" json:"id,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	JournalEntryID uint64          `gorm:"not null;index" json:"journal_entry_id"` // 记账凭证 ID
+	AccountID      uint64          `gorm:"not null;index" json:"account_id"`       // 账户 ID
+	Amount         decimal.Decimal `gorm:"type:numeric;not null" json:"amount"`    // 金额，单位 ETH
+}
+
+// ReconcileCheckpoint 定时对账已扫描到的区块
+type ReconcileCheckpoint struct {
+	Address    string     `gorm:"primaryKey" json:"address"` // 发送地址
+	UpdatedAt  time.Time  `json:"updated_at"`
+	LastBlock  uint64     `gorm:"not null" json:"last_block"` // 已扫描的最后一个区块
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`      // 最后一轮对账开始的时间，此前更新的上链成功的提款申请已经检查过
+}
+
+// AssetETH 以太坊原生资产，目前唯一支持的资产
+const AssetETH = "ETH"
 
-	// Migrate the schema
-	err = db.AutoMigrate(
-		&Withdrawal{},
-		&WithdrawalConfirmation{},
-	)
+// 数据库类型
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite" // 纯 Go 实现，用于本地运行和测试
+)
+
+// DefaultPostgresDSN docker compose 中的 postgres
+const DefaultPostgresDSN = "host=task-postgres user=gorm password=gorm dbname=gorm port=5432 sslmode=disable TimeZone=Asia/Shanghai"
+
+// Init 连接数据库，失败时 panic
+func Init(driver, dsn string) *gorm.DB {
+	db, err := Open(driver, dsn)
 	if err != nil {
 		panic(err)
 	}
 
 	return db
 }
+
+// Open 连接数据库
+// 表结构由 migrate 包的版本化迁移维护，见 server migrate up。
+func Open(driver, dsn string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch driver {
+	case DriverPostgres:
+		dialector = postgres.Open(dsn)
+	case DriverSQLite:
+		dialector = sqlite.Open(sqliteDSN(dsn))
+	default:
+		return nil, fmt.Errorf("unknown database driver: %s", driver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect database: %w", err)
+	}
+	return db, nil
+}
+
+// sqliteDSN 补充 SQLite 连接参数
+// SQLite 同一时间只允许一个写事务：事务开始时即获取写锁（_txlock=immediate），其他写事务等待而不是立即失败；
+// WAL 模式下读不阻塞写，事务未提交时其他连接仍然可以读取。
+func sqliteDSN(dsn string) string {
+	params := []struct{ key, value string }{
+		{"_txlock=", "immediate"},
+		{"_pragma=busy_timeout(", "30000)"},
+		{"_pragma=journal_mode(", "WAL)"},
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	for _, p := range params {
+		// 调用方已经指定的参数不覆盖
+		if strings.Contains(dsn, p.key) {
+			continue
+		}
+		dsn += sep + p.key + p.value
+		sep = "&"
+	}
+	return dsn
+}
@@ -0,0 +1,234 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/big"
+	"strings"
+	"time"
+
+	"task/cmd/app/eth"
+	"task/cmd/app/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/umbracle/ethgo"
+	"gorm.io/gorm"
+)
+
+// Sender 广播签名交易
+type Sender interface {
+	SendRawTransactionContext(ctx context.Context, data []byte) (ethgo.Hash, error)
+}
+
+// Relay 负责把 outbox 中的签名交易广播上链
+// 状态机签名后先调用 Enqueue 提交记录，再调用 Send 立即广播；
+// Run 在后台定期重新广播未成功的记录，覆盖广播失败、进程崩溃等情况。
+type Relay struct {
+	db          *gorm.DB
+	sender      Sender
+	interval    time.Duration // 扫描间隔
+	minAge      time.Duration // 只重新广播落库超过该时长的记录，避免与正在执行的状态机同时广播
+	maxAttempts int           // 最大广播次数，超过后标记为放弃
+}
+
+func NewRelay(db *gorm.DB, sender Sender) *Relay {
+	return &Relay{
+		db:          db,
+		sender:      sender,
+		interval:    time.Second * 10,
+		minAge:      time.Second * 30,
+		maxAttempts: 10,
+	}
+}
+
+// In 返回在 tx 中读写 outbox 的 Relay，用于状态机
+// postgres 下返回 r 本身，Enqueue 独立提交；SQLite 同一时间只允许一个写事务，
+// 调用方事务未提交时其他连接无法写入，只能随调用方事务一起提交，调用方需要在广播前提交事务。
+func (r *Relay) In(tx *gorm.DB) *Relay {
+	if r == nil || r.db.Dialector.Name() != model.DriverSQLite {
+		return r
+	}
+	c := *r
+	c.db = tx
+	return &c
+}
+
+// Enqueue 提交一笔待广播交易
+// 使用 Relay 自身的 db 而不是调用方的事务，返回时记录已经提交，即使调用方事务回滚也不会丢失。
+func (r *Relay) Enqueue(withdrawalID uint64, signed *eth.SignedTransaction) (*model.Outbox, error) {
+	entry := &model.Outbox{
+		WithdrawalID: withdrawalID,
+		TxHash:       signed.Hash.String(),
+		RawTx:        signed.Raw,
+		Nonce:        signed.Nonce,
+		Status:       uint64(model.OutboxPending),
+		FromAddress:  signed.From,
+		GasLimit:     signed.GasLimit,
+		GasPrice:     wei(signed.GasPrice),
+	}
+	err := r.db.Create(entry).Error
+	if err != nil {
+		slog.Error("create outbox failed", "withdrawal_id", withdrawalID, "tx_hash", entry.TxHash, "nonce", entry.Nonce, "err", err)
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Latest 查询提款申请最近一笔未放弃的交易，没有则返回 nil
+func (r *Relay) Latest(withdrawalID uint64) (*model.Outbox, error) {
+	var entry model.Outbox
+	err := r.db.Where("withdrawal_id = ?", withdrawalID).
+		Where("status != ?", model.OutboxFailed).
+		Order("id desc").
+		First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// RecordReceipt 按 receipt 记录交易实际的 gas 消耗和手续费，返回更新后的记录，可以重复调用
+// 只签名 legacy 交易，实际的 gas price 就是签名时的 gas price；早于记录 gas price 的交易从签名交易中解析。
+func RecordReceipt(db *gorm.DB, txHash string, receipt *ethgo.Receipt) (*model.Outbox, error) {
+	var entry model.Outbox
+	err := db.Where("tx_hash = ?", txHash).First(&entry).Error
+	if err != nil {
+		return nil, err
+	}
+
+	price := entry.GasPrice
+	if price.IsZero() {
+		gasLimit, gasPrice, err := eth.DecodeGas(entry.RawTx)
+		if err != nil {
+			slog.Warn("decode outbox transaction failed", "tx_hash", txHash, "err", err)
+		} else {
+			entry.GasLimit = gasLimit
+			price = wei(gasPrice)
+		}
+	}
+
+	entry.GasUsed = receipt.GasUsed
+	entry.EffectiveGasPrice = price
+	entry.FeeWei = price.Mul(wei(receipt.GasUsed))
+	updates := map[string]interface{}{
+		"gas_limit":           entry.GasLimit,
+		"gas_used":            entry.GasUsed,
+		"effective_gas_price": entry.EffectiveGasPrice,
+		"fee_wei":             entry.FeeWei,
+	}
+	if entry.MinedAt == nil {
+		now := time.Now()
+		entry.MinedAt = &now
+		updates["mined_at"] = entry.MinedAt
+	}
+	err = db.Model(&entry).Updates(updates).Error
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// TotalFee 提款申请所有交易的手续费之和，单位 wei
+func TotalFee(db *gorm.DB, withdrawalID uint64) (decimal.Decimal, error) {
+	var fees []decimal.Decimal
+	err := db.Model(&model.Outbox{}).Where("withdrawal_id = ?", withdrawalID).Pluck("fee_wei", &fees).Error
+	if err != nil {
+		return decimal.Zero, err
+	}
+	total := decimal.Zero
+	for _, fee := range fees {
+		total = total.Add(fee)
+	}
+	return total, nil
+}
+
+// wei uint64 转换为 decimal
+func wei(v uint64) decimal.Decimal {
+	return decimal.NewFromBigInt(new(big.Int).SetUint64(v), 0)
+}
+
+// Send 广播一笔交易并更新记录状态
+// 节点已经收到过同一笔交易时视为广播成功，因此可以重复调用。
+// ctx 用于日志关联和链路追踪。
+func (r *Relay) Send(ctx context.Context, entry *model.Outbox) error {
+	entry.Attempts++
+	_, sendErr := r.sender.SendRawTransactionContext(ctx, entry.RawTx)
+	if sendErr != nil && isKnownTransaction(sendErr) {
+		sendErr = nil
+	}
+
+	updates := map[string]interface{}{
+		"attempts": entry.Attempts,
+	}
+	if sendErr == nil {
+		now := time.Now()
+		entry.Status = uint64(model.OutboxSent)
+		entry.SentAt = &now
+		entry.LastError = ""
+		updates["status"] = entry.Status
+		updates["sent_at"] = entry.SentAt
+		updates["last_error"] = ""
+	} else {
+		slog.WarnContext(ctx, "send raw transaction failed", "withdrawal_id", entry.WithdrawalID,
+			"tx_hash", entry.TxHash, "nonce", entry.Nonce, "attempts", entry.Attempts, "err", sendErr)
+		entry.LastError = sendErr.Error()
+		updates["last_error"] = entry.LastError
+		if entry.Status == uint64(model.OutboxPending) && entry.Attempts >= r.maxAttempts {
+			entry.Status = uint64(model.OutboxFailed)
+			updates["status"] = entry.Status
+		}
+	}
+
+	err := r.db.Model(entry).Updates(updates).Error
+	if err != nil {
+		slog.ErrorContext(ctx, "update outbox failed", "withdrawal_id", entry.WithdrawalID,
+			"tx_hash", entry.TxHash, "nonce", entry.Nonce, "err", err)
+		if sendErr == nil {
+			return err
+		}
+	}
+	return sendErr
+}
+
+// Run 定期重新广播未广播成功的交易，直到 ctx 取消
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.relayPending(ctx)
+		}
+	}
+}
+
+func (r *Relay) relayPending(ctx context.Context) {
+	var entries []*model.Outbox
+	err := r.db.Where("status = ?", model.OutboxPending).
+		Where("created_at < ?", time.Now().Add(-r.minAge)).
+		Order("id").
+		Find(&entries).Error
+	if err != nil {
+		slog.ErrorContext(ctx, "find outbox failed", "err", err)
+		return
+	}
+
+	for _, entry := range entries {
+		_ = r.Send(ctx, entry)
+	}
+}
+
+// isKnownTransaction 节点返回交易已存在
+func isKnownTransaction(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "already known") ||
+		strings.Contains(msg, "known transaction") ||
+		strings.Contains(msg, "already imported")
+}
@@ -0,0 +1,396 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: withdrawal.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	WithdrawalService_Create_FullMethodName          = "/withdrawal.v1.WithdrawalService/Create"
+	WithdrawalService_Get_FullMethodName             = "/withdrawal.v1.WithdrawalService/Get"
+	WithdrawalService_List_FullMethodName            = "/withdrawal.v1.WithdrawalService/List"
+	WithdrawalService_Approve_FullMethodName         = "/withdrawal.v1.WithdrawalService/Approve"
+	WithdrawalService_RevokeApproval_FullMethodName  = "/withdrawal.v1.WithdrawalService/RevokeApproval"
+	WithdrawalService_Reject_FullMethodName          = "/withdrawal.v1.WithdrawalService/Reject"
+	WithdrawalService_Execute_FullMethodName         = "/withdrawal.v1.WithdrawalService/Execute"
+	WithdrawalService_WatchWithdrawal_FullMethodName = "/withdrawal.v1.WithdrawalService/WatchWithdrawal"
+)
+
+// WithdrawalServiceClient is the client API for WithdrawalService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WithdrawalServiceClient interface {
+	Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error)
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Withdrawal, error)
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	Approve(ctx context.Context, in *ApproveRequest, opts ...grpc.CallOption) (*ApproveResponse, error)
+	RevokeApproval(ctx context.Context, in *RevokeApprovalRequest, opts ...grpc.CallOption) (*ApproveResponse, error)
+	Reject(ctx context.Context, in *RejectRequest, opts ...grpc.CallOption) (*Withdrawal, error)
+	Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (*Withdrawal, error)
+	WatchWithdrawal(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (WithdrawalService_WatchWithdrawalClient, error)
+}
+
+type withdrawalServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWithdrawalServiceClient(cc grpc.ClientConnInterface) WithdrawalServiceClient {
+	return &withdrawalServiceClient{cc}
+}
+
+func (c *withdrawalServiceClient) Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error) {
+	out := new(CreateResponse)
+	err := c.cc.Invoke(ctx, WithdrawalService_Create_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *withdrawalServiceClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Withdrawal, error) {
+	out := new(Withdrawal)
+	err := c.cc.Invoke(ctx, WithdrawalService_Get_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *withdrawalServiceClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, WithdrawalService_List_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *withdrawalServiceClient) Approve(ctx context.Context, in *ApproveRequest, opts ...grpc.CallOption) (*ApproveResponse, error) {
+	out := new(ApproveResponse)
+	err := c.cc.Invoke(ctx, WithdrawalService_Approve_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *withdrawalServiceClient) RevokeApproval(ctx context.Context, in *RevokeApprovalRequest, opts ...grpc.CallOption) (*ApproveResponse, error) {
+	out := new(ApproveResponse)
+	err := c.cc.Invoke(ctx, WithdrawalService_RevokeApproval_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *withdrawalServiceClient) Reject(ctx context.Context, in *RejectRequest, opts ...grpc.CallOption) (*Withdrawal, error) {
+	out := new(Withdrawal)
+	err := c.cc.Invoke(ctx, WithdrawalService_Reject_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *withdrawalServiceClient) Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (*Withdrawal, error) {
+	out := new(Withdrawal)
+	err := c.cc.Invoke(ctx, WithdrawalService_Execute_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *withdrawalServiceClient) WatchWithdrawal(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (WithdrawalService_WatchWithdrawalClient, error) {
+	stream, err := c.cc.NewStream(ctx, &WithdrawalService_ServiceDesc.Streams[0], WithdrawalService_WatchWithdrawal_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &withdrawalServiceWatchWithdrawalClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type WithdrawalService_WatchWithdrawalClient interface {
+	Recv() (*WithdrawalEvent, error)
+	grpc.ClientStream
+}
+
+type withdrawalServiceWatchWithdrawalClient struct {
+	grpc.ClientStream
+}
+
+func (x *withdrawalServiceWatchWithdrawalClient) Recv() (*WithdrawalEvent, error) {
+	m := new(WithdrawalEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WithdrawalServiceServer is the server API for WithdrawalService service.
+// All implementations must embed UnimplementedWithdrawalServiceServer
+// for forward compatibility
+type WithdrawalServiceServer interface {
+	Create(context.Context, *CreateRequest) (*CreateResponse, error)
+	Get(context.Context, *GetRequest) (*Withdrawal, error)
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	Approve(context.Context, *ApproveRequest) (*ApproveResponse, error)
+	RevokeApproval(context.Context, *RevokeApprovalRequest) (*ApproveResponse, error)
+	Reject(context.Context, *RejectRequest) (*Withdrawal, error)
+	Execute(context.Context, *ExecuteRequest) (*Withdrawal, error)
+	WatchWithdrawal(*WatchRequest, WithdrawalService_WatchWithdrawalServer) error
+	mustEmbedUnimplementedWithdrawalServiceServer()
+}
+
+// UnimplementedWithdrawalServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedWithdrawalServiceServer struct {
+}
+
+func (UnimplementedWithdrawalServiceServer) Create(context.Context, *CreateRequest) (*CreateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedWithdrawalServiceServer) Get(context.Context, *GetRequest) (*Withdrawal, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedWithdrawalServiceServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedWithdrawalServiceServer) Approve(context.Context, *ApproveRequest) (*ApproveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Approve not implemented")
+}
+func (UnimplementedWithdrawalServiceServer) RevokeApproval(context.Context, *RevokeApprovalRequest) (*ApproveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeApproval not implemented")
+}
+func (UnimplementedWithdrawalServiceServer) Reject(context.Context, *RejectRequest) (*Withdrawal, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reject not implemented")
+}
+func (UnimplementedWithdrawalServiceServer) Execute(context.Context, *ExecuteRequest) (*Withdrawal, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Execute not implemented")
+}
+func (UnimplementedWithdrawalServiceServer) WatchWithdrawal(*WatchRequest, WithdrawalService_WatchWithdrawalServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchWithdrawal not implemented")
+}
+func (UnimplementedWithdrawalServiceServer) mustEmbedUnimplementedWithdrawalServiceServer() {}
+
+// UnsafeWithdrawalServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WithdrawalServiceServer will
+// result in compilation errors.
+type UnsafeWithdrawalServiceServer interface {
+	mustEmbedUnimplementedWithdrawalServiceServer()
+}
+
+func RegisterWithdrawalServiceServer(s grpc.ServiceRegistrar, srv WithdrawalServiceServer) {
+	s.RegisterService(&WithdrawalService_ServiceDesc, srv)
+}
+
+func _WithdrawalService_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WithdrawalServiceServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WithdrawalService_Create_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WithdrawalServiceServer).Create(ctx, req.(*CreateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WithdrawalService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WithdrawalServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WithdrawalService_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WithdrawalServiceServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WithdrawalService_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WithdrawalServiceServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WithdrawalService_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WithdrawalServiceServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WithdrawalService_Approve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApproveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WithdrawalServiceServer).Approve(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WithdrawalService_Approve_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WithdrawalServiceServer).Approve(ctx, req.(*ApproveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WithdrawalService_RevokeApproval_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeApprovalRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WithdrawalServiceServer).RevokeApproval(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WithdrawalService_RevokeApproval_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WithdrawalServiceServer).RevokeApproval(ctx, req.(*RevokeApprovalRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WithdrawalService_Reject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RejectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WithdrawalServiceServer).Reject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WithdrawalService_Reject_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WithdrawalServiceServer).Reject(ctx, req.(*RejectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WithdrawalService_Execute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExecuteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WithdrawalServiceServer).Execute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WithdrawalService_Execute_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WithdrawalServiceServer).Execute(ctx, req.(*ExecuteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WithdrawalService_WatchWithdrawal_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WithdrawalServiceServer).WatchWithdrawal(m, &withdrawalServiceWatchWithdrawalServer{stream})
+}
+
+type WithdrawalService_WatchWithdrawalServer interface {
+	Send(*WithdrawalEvent) error
+	grpc.ServerStream
+}
+
+type withdrawalServiceWatchWithdrawalServer struct {
+	grpc.ServerStream
+}
+
+func (x *withdrawalServiceWatchWithdrawalServer) Send(m *WithdrawalEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// WithdrawalService_ServiceDesc is the grpc.ServiceDesc for WithdrawalService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var WithdrawalService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "withdrawal.v1.WithdrawalService",
+	HandlerType: (*WithdrawalServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Create",
+			Handler:    _WithdrawalService_Create_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _WithdrawalService_Get_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _WithdrawalService_List_Handler,
+		},
+		{
+			MethodName: "Approve",
+			Handler:    _WithdrawalService_Approve_Handler,
+		},
+		{
+			MethodName: "RevokeApproval",
+			Handler:    _WithdrawalService_RevokeApproval_Handler,
+		},
+		{
+			MethodName: "Reject",
+			Handler:    _WithdrawalService_Reject_Handler,
+		},
+		{
+			MethodName: "Execute",
+			Handler:    _WithdrawalService_Execute_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchWithdrawal",
+			Handler:       _WithdrawalService_WatchWithdrawal_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "withdrawal.proto",
+}
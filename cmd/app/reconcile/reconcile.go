@@ -0,0 +1,448 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"task/cmd/app/eth"
+	"task/cmd/app/ledger"
+	"task/cmd/app/model"
+	"task/cmd/app/outbox"
+
+	"github.com/umbracle/ethgo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kind 差异类型
+type Kind string
+
+const (
+	KindSuccessWithoutReceipt Kind = "success_without_receipt" // 数据库为上链成功，链上没有成功的 receipt
+	KindMinedNotRecorded      Kind = "mined_not_recorded"      // 数据库为非终态，交易已经上链
+	KindHashNotRecorded       Kind = "hash_not_recorded"       // 提款申请没有保存 tx hash，outbox 中的交易已经上链
+	KindTransactionNotFound   Kind = "transaction_not_found"   // 提款申请的 tx hash 在链上查不到
+	KindOrphanTransaction     Kind = "orphan_transaction"      // 发送地址的链上交易没有对应的提款申请
+)
+
+// Discrepancy 一条数据库与链上不一致的记录
+type Discrepancy struct {
+	Kind         Kind   `json:"kind"`
+	WithdrawalID uint64 `json:"withdrawal_id,omitempty"`
+	TxHash       string `json:"tx_hash,omitempty"`
+	DBStatus     string `json:"db_status,omitempty"`
+	ChainStatus  string `json:"chain_status,omitempty"`
+	BlockNumber  uint64 `json:"block_number,omitempty"`
+	Detail       string `json:"detail,omitempty"`
+	Fixed        bool   `json:"fixed"` // 是否已自动修复
+}
+
+// Report 对账报告
+type Report struct {
+	GeneratedAt   time.Time      `json:"generated_at"`
+	FromBlock     uint64         `json:"from_block"`
+	ToBlock       uint64         `json:"to_block"`
+	Withdrawals   int            `json:"withdrawals"`  // 检查的提款申请数
+	Transactions  int            `json:"transactions"` // 检查的链上交易数
+	Discrepancies []*Discrepancy `json:"discrepancies"`
+}
+
+// WriteJSON 以 JSON 输出报告
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV 以 CSV 输出差异列表
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"kind", "withdrawal_id", "tx_hash", "db_status", "chain_status", "block_number", "detail", "fixed"})
+	if err != nil {
+		return err
+	}
+	for _, d := range r.Discrepancies {
+		err = cw.Write([]string{
+			string(d.Kind),
+			strconv.FormatUint(d.WithdrawalID, 10),
+			d.TxHash,
+			d.DBStatus,
+			d.ChainStatus,
+			strconv.FormatUint(d.BlockNumber, 10),
+			d.Detail,
+			strconv.FormatBool(d.Fixed),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Chain 对账需要的链上查询
+type Chain interface {
+	BlockNumber() (uint64, error)
+	GetBlockByNumber(i ethgo.BlockNumber, full bool) (*ethgo.Block, error)
+	GetTransactionByHash(hash ethgo.Hash) (*ethgo.Transaction, error)
+	GetTransactionReceipt(hash ethgo.Hash) (*ethgo.Receipt, error)
+}
+
+// Options 对账配置
+type Options struct {
+	FromBlock uint64    // 扫描发送地址交易的起始区块
+	AutoFix   bool      // 是否自动修复安全的差异
+	Since     time.Time // 只重新检查此后更新过的上链成功的提款申请，零值表示全部检查
+}
+
+// batchSize 每次读取的提款申请数
+const batchSize = 500
+
+// Reconciler 对比数据库中的提款申请与链上交易
+// 只自动修复可以由链上结果唯一确定的情况：非终态但交易已上链，或 tx hash 只记录在 outbox 中。
+// 其余差异只报告，由人工处理。
+type Reconciler struct {
+	db     *gorm.DB
+	chain  Chain
+	sender ethgo.Address
+}
+
+func New(db *gorm.DB, chain Chain) *Reconciler {
+	return &Reconciler{
+		db:     db,
+		chain:  chain,
+		sender: ethgo.HexToAddress(eth.From),
+	}
+}
+
+// Run 生成一份对账报告
+func (r *Reconciler) Run(ctx context.Context, opts Options) (*Report, error) {
+	latest, err := r.chain.BlockNumber()
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{
+		GeneratedAt:   time.Now(),
+		FromBlock:     opts.FromBlock,
+		ToBlock:       latest,
+		Discrepancies: []*Discrepancy{},
+	}
+
+	// 1) 检查未结束的提款申请和 opts.Since 之后更新过的上链成功的提款申请，按 ID 分页读取
+	var lastID uint
+	for {
+		var withdrawals []*model.Withdrawal
+		err = r.candidates(opts.Since).Where("id > ?", lastID).Order("id").Limit(batchSize).Find(&withdrawals).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, withdrawal := range withdrawals {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			d, err := r.checkWithdrawal(withdrawal, opts.AutoFix)
+			if err != nil {
+				return nil, err
+			}
+			if d != nil {
+				report.Discrepancies = append(report.Discrepancies, d)
+			}
+			lastID = withdrawal.ID
+		}
+		report.Withdrawals += len(withdrawals)
+		if len(withdrawals) < batchSize {
+			break
+		}
+	}
+
+	// 2) 扫描发送地址的链上交易
+	for n := opts.FromBlock; n <= latest; n++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		block, err := r.chain.GetBlockByNumber(ethgo.BlockNumber(n), true)
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			continue
+		}
+		for _, txn := range block.Transactions {
+			if txn.From != r.sender {
+				continue
+			}
+			report.Transactions++
+			known, err := r.known(txn.Hash)
+			if err != nil {
+				return nil, err
+			}
+			if known {
+				continue
+			}
+			report.Discrepancies = append(report.Discrepancies, &Discrepancy{
+				Kind:        KindOrphanTransaction,
+				TxHash:      txn.Hash.String(),
+				BlockNumber: n,
+				Detail:      "no withdrawal or outbox entry for transaction from sender",
+			})
+		}
+	}
+
+	return report, nil
+}
+
+// candidates 需要检查的提款申请
+// 已拒绝和已过期的提款申请没有上链的交易，不检查；上链成功的只检查 since 之后更新过的。
+func (r *Reconciler) candidates(since time.Time) *gorm.DB {
+	query := r.db.Model(&model.Withdrawal{}).
+		Where("status NOT IN ?", []uint64{uint64(model.StateRejected), uint64(model.StateExpired)})
+	if !since.IsZero() {
+		query = query.Where("(status != ? OR updated_at >= ?)", uint64(model.StateSuccess), since)
+	}
+	return query
+}
+
+// known 链上交易是否记录在 outbox 或提款申请中
+func (r *Reconciler) known(hash ethgo.Hash) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.Outbox{}).Where("tx_hash = ?", hash.String()).Count(&count).Error
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+	err = r.db.Model(&model.Withdrawal{}).Where("tx_hash = ?", hash.String()).Count(&count).Error
+	return count > 0, err
+}
+
+// checkWithdrawal 检查单个提款申请，返回 nil 表示一致
+func (r *Reconciler) checkWithdrawal(withdrawal *model.Withdrawal, autoFix bool) (*Discrepancy, error) {
+	status := model.WithdrawalState(withdrawal.Status)
+	txHash := withdrawal.TxHash
+	kind := KindMinedNotRecorded
+
+	// 没有 tx hash：查 outbox 中最近一笔交易是否已经上链
+	if txHash == "" {
+		var entry model.Outbox
+		err := r.db.Where("withdrawal_id = ?", withdrawal.ID).Order("id desc").Limit(1).Find(&entry).Error
+		if err != nil {
+			return nil, err
+		}
+		if entry.ID == 0 {
+			return nil, nil
+		}
+		txHash = entry.TxHash
+		kind = KindHashNotRecorded
+	}
+
+	receipt, err := r.chain.GetTransactionReceipt(ethgo.HexToHash(txHash))
+	if err != nil {
+		return nil, err
+	}
+
+	if receipt == nil {
+		if kind == KindHashNotRecorded {
+			// outbox 中的交易尚未上链，由 outbox relay 处理
+			return nil, nil
+		}
+		if status == model.StateSuccess {
+			return &Discrepancy{
+				Kind:         KindSuccessWithoutReceipt,
+				WithdrawalID: uint64(withdrawal.ID),
+				TxHash:       txHash,
+				DBStatus:     status.String(),
+				ChainStatus:  "no receipt",
+			}, nil
+		}
+		txn, err := r.chain.GetTransactionByHash(ethgo.HexToHash(txHash))
+		if err != nil {
+			return nil, err
+		}
+		if txn == nil {
+			return &Discrepancy{
+				Kind:         KindTransactionNotFound,
+				WithdrawalID: uint64(withdrawal.ID),
+				TxHash:       txHash,
+				DBStatus:     status.String(),
+				ChainStatus:  "not found",
+			}, nil
+		}
+		// 在交易池中等待打包
+		return nil, nil
+	}
+
+	chainStatus := model.StateFailure
+	if receipt.Status == 1 {
+		chainStatus = model.StateSuccess
+	}
+	if status == chainStatus && kind != KindHashNotRecorded {
+		return nil, nil
+	}
+	if status == model.StateSuccess {
+		return &Discrepancy{
+			Kind:         KindSuccessWithoutReceipt,
+			WithdrawalID: uint64(withdrawal.ID),
+			TxHash:       txHash,
+			DBStatus:     status.String(),
+			ChainStatus:  chainStatus.String(),
+			BlockNumber:  receipt.BlockNumber,
+		}, nil
+	}
+
+	d := &Discrepancy{
+		Kind:         kind,
+		WithdrawalID: uint64(withdrawal.ID),
+		TxHash:       txHash,
+		DBStatus:     status.String(),
+		ChainStatus:  chainStatus.String(),
+		BlockNumber:  receipt.BlockNumber,
+	}
+	if autoFix {
+		d.Fixed, err = r.fix(withdrawal, txHash, chainStatus, receipt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// fix 按链上结果更新提款申请，只有版本号未变（状态和 tx hash 未被其他请求修改）时才更新
+// 在同一个事务中结算或释放账本中的预留。
+func (r *Reconciler) fix(withdrawal *model.Withdrawal, txHash string, status model.WithdrawalState, receipt *ethgo.Receipt) (bool, error) {
+	fixed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"tx_hash": txHash,
+			"status":  uint64(status),
+			"version": gorm.Expr("version + 1"),
+		}
+		// 记录实际的 gas 消耗，outbox 中没有的交易（如 tx hash 只在提款申请中）不记录
+		entry, err := outbox.RecordReceipt(tx, txHash, receipt)
+		if err == nil {
+			withdrawal.FeeWei, err = outbox.TotalFee(tx, uint64(withdrawal.ID))
+		}
+		if err != nil {
+			slog.Error("record gas failed", "withdrawal_id", withdrawal.ID, "tx_hash", txHash, "err", err)
+		} else {
+			updates["gas_limit"] = entry.GasLimit
+			updates["gas_used"] = entry.GasUsed
+			updates["effective_gas_price"] = entry.EffectiveGasPrice
+			updates["fee_wei"] = withdrawal.FeeWei
+		}
+
+		result := tx.Model(&model.Withdrawal{}).
+			Where("id = ? AND version = ?", withdrawal.ID, withdrawal.Version).
+			Where("status = ? AND tx_hash = ?", withdrawal.Status, withdrawal.TxHash).
+			Updates(updates)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		fixed = true
+
+		if status != model.StateSuccess {
+			return ledger.Release(tx, withdrawal, "reconcile")
+		}
+		return ledger.Settle(tx, withdrawal, withdrawal.FeeWei.Shift(-18))
+	})
+	if err != nil || !fixed {
+		return false, err
+	}
+	slog.Info("reconcile fixed withdrawal", "withdrawal_id", withdrawal.ID, "tx_hash", txHash,
+		"from", model.WithdrawalState(withdrawal.Status).String(), "to", status.String())
+	return true, nil
+}
+
+// lockKey postgres advisory lock 的 key，多个副本中同一时间只有一个执行定时对账
+const lockKey = 72_657_002
+
+// Schedule 定期对账并自动修复，直到 ctx 取消
+// 每一轮从上一轮保存的区块之后开始扫描；没有记录时（首次运行）从最新区块往前 lookback 个区块开始。
+// 上链成功的提款申请只检查上一轮开始之后更新过的，其余未结束的提款申请每一轮都检查。
+func (r *Reconciler) Schedule(ctx context.Context, interval time.Duration, lookback uint64) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := r.tick(ctx, lookback)
+		if err != nil {
+			slog.ErrorContext(ctx, "reconcile failed", "err", err)
+			continue
+		}
+		if report == nil {
+			continue
+		}
+		for _, d := range report.Discrepancies {
+			slog.WarnContext(ctx, "reconcile discrepancy", "kind", d.Kind, "withdrawal_id", d.WithdrawalID, "tx_hash", d.TxHash,
+				"db_status", d.DBStatus, "chain_status", d.ChainStatus, "fixed", d.Fixed)
+		}
+	}
+}
+
+// tick 执行一轮定时对账并保存扫描到的区块，其他副本正在对账时跳过，返回 nil
+// 只有 postgres 支持 advisory lock，其他数据库直接执行。
+func (r *Reconciler) tick(ctx context.Context, lookback uint64) (*Report, error) {
+	var report *Report
+	err := r.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		// 每次查询使用新的 Statement，记录不存在等错误不影响之后的语句
+		conn = conn.Session(&gorm.Session{})
+		if conn.Dialector.Name() == model.DriverPostgres {
+			var locked bool
+			err := conn.Raw("SELECT pg_try_advisory_lock(?)", lockKey).Scan(&locked).Error
+			if err != nil || !locked {
+				return err
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", lockKey)
+		}
+
+		opts, err := r.options(conn, lookback)
+		if err != nil {
+			return err
+		}
+		report, err = r.Run(ctx, opts)
+		if err != nil {
+			return err
+		}
+		return conn.Clauses(clause.OnConflict{UpdateAll: true}).Create(&model.ReconcileCheckpoint{
+			Address:    r.sender.String(),
+			LastBlock:  report.ToBlock,
+			VerifiedAt: &report.GeneratedAt,
+		}).Error
+	})
+	return report, err
+}
+
+// options 按上一轮保存的记录确定本轮扫描的起始区块和需要重新检查的上链成功的提款申请
+func (r *Reconciler) options(db *gorm.DB, lookback uint64) (Options, error) {
+	opts := Options{AutoFix: true}
+	var checkpoint model.ReconcileCheckpoint
+	err := db.Take(&checkpoint, "address = ?", r.sender.String()).Error
+	if err == nil {
+		opts.FromBlock = checkpoint.LastBlock + 1
+		if checkpoint.VerifiedAt != nil {
+			opts.Since = *checkpoint.VerifiedAt
+		}
+		return opts, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return opts, err
+	}
+
+	latest, err := r.chain.BlockNumber()
+	if err != nil {
+		return opts, err
+	}
+	if latest > lookback {
+		opts.FromBlock = latest - lookback
+	}
+	return opts, nil
+}
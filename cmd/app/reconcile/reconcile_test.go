@@ -0,0 +1,181 @@
+package reconcile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"task/cmd/app/migrate"
+	"task/cmd/app/model"
+
+	"github.com/umbracle/ethgo"
+	"gorm.io/gorm"
+)
+
+func openSQLite(t *testing.T) *gorm.DB {
+	db, err := model.Open(model.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.New(db)
+	if err == nil {
+		_, err = m.Up(context.Background())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// fakeChain 只有空区块的链，记录扫描过的区块
+type fakeChain struct {
+	head    uint64
+	scanned []uint64
+}
+
+func (c *fakeChain) BlockNumber() (uint64, error) {
+	return c.head, nil
+}
+
+func (c *fakeChain) GetBlockByNumber(i ethgo.BlockNumber, _ bool) (*ethgo.Block, error) {
+	c.scanned = append(c.scanned, uint64(i))
+	return &ethgo.Block{Number: uint64(i)}, nil
+}
+
+func (c *fakeChain) GetTransactionByHash(ethgo.Hash) (*ethgo.Transaction, error) {
+	return nil, nil
+}
+
+func (c *fakeChain) GetTransactionReceipt(ethgo.Hash) (*ethgo.Receipt, error) {
+	return nil, nil
+}
+
+func testReport() *Report {
+	return &Report{
+		Discrepancies: []*Discrepancy{
+			{Kind: KindMinedNotRecorded, WithdrawalID: 1, TxHash: "0x01", DBStatus: "pending", ChainStatus: "success", BlockNumber: 7, Fixed: true},
+			{Kind: KindOrphanTransaction, TxHash: "0x02", BlockNumber: 8, Detail: "a, b"},
+		},
+	}
+}
+
+func TestReportWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"kind,withdrawal_id,tx_hash,db_status,chain_status,block_number,detail,fixed",
+		"mined_not_recorded,1,0x01,pending,success,7,,true",
+		`orphan_transaction,0,0x02,,,8,"a, b",false`,
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestReportWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got Report
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Discrepancies) != 2 || got.Discrepancies[0].Kind != KindMinedNotRecorded || !got.Discrepancies[0].Fixed {
+		t.Fatalf("unexpected report: %s", buf.String())
+	}
+}
+
+// 首次对账从最新区块往前 lookback 个区块开始，之后从保存的区块继续，重启后不会从创世区块重新扫描
+func TestTickCheckpoint(t *testing.T) {
+	db := openSQLite(t)
+	chain := &fakeChain{head: 100}
+	ctx := context.Background()
+
+	if _, err := New(db, chain).tick(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	if len(chain.scanned) != 11 || chain.scanned[0] != 90 {
+		t.Fatalf("unexpected first scan: %v", chain.scanned)
+	}
+
+	// 新的 Reconciler 模拟重启
+	chain.head, chain.scanned = 103, nil
+	report, err := New(db, chain).tick(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FromBlock != 101 || len(chain.scanned) != 3 || chain.scanned[0] != 101 {
+		t.Fatalf("unexpected scan after restart: from=%d, scanned=%v", report.FromBlock, chain.scanned)
+	}
+
+	// 没有新区块时不扫描
+	chain.scanned = nil
+	if _, err := New(db, chain).tick(ctx, 10); err != nil || len(chain.scanned) != 0 {
+		t.Fatalf("unexpected scan without new blocks: %v, err=%v", chain.scanned, err)
+	}
+}
+
+// 每一轮只检查未结束的提款申请和上一轮开始之后更新过的上链成功的提款申请，分页读取
+func TestRunCandidates(t *testing.T) {
+	db := openSQLite(t)
+	now := time.Now()
+	old := now.Add(-time.Hour)
+
+	var withdrawals []*model.Withdrawal
+	for i := 0; i < batchSize+10; i++ {
+		withdrawals = append(withdrawals, &model.Withdrawal{Status: uint64(model.StatePending), CreatedAt: old, UpdatedAt: old})
+	}
+	for _, status := range []model.WithdrawalState{model.StateSuccess, model.StateRejected, model.StateExpired} {
+		withdrawals = append(withdrawals, &model.Withdrawal{Status: uint64(status), TxHash: "0x01", CreatedAt: old, UpdatedAt: old})
+	}
+	withdrawals = append(withdrawals, &model.Withdrawal{Status: uint64(model.StateSuccess), TxHash: "0x02", CreatedAt: old, UpdatedAt: now})
+	if err := db.CreateInBatches(withdrawals, 100).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	chain := &fakeChain{head: 100}
+	r := New(db, chain)
+	report, err := r.Run(context.Background(), Options{FromBlock: 100, Since: now.Add(-time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Withdrawals != batchSize+11 {
+		t.Fatalf("checked %d withdrawals, want %d", report.Withdrawals, batchSize+11)
+	}
+	if len(report.Discrepancies) != 1 || report.Discrepancies[0].TxHash != "0x02" {
+		t.Fatalf("unexpected discrepancies: %+v", report.Discrepancies)
+	}
+
+	// 不限制时检查所有上链成功的提款申请
+	report, err = r.Run(context.Background(), Options{FromBlock: 100})
+	if err != nil || report.Withdrawals != batchSize+12 {
+		t.Fatalf("checked %d withdrawals, err=%v", report.Withdrawals, err)
+	}
+
+	// 定时对账保存本轮开始的时间，下一轮从此开始
+	report, err = r.tick(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var checkpoint model.ReconcileCheckpoint
+	if err = db.Take(&checkpoint).Error; err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.VerifiedAt == nil || !checkpoint.VerifiedAt.Equal(report.GeneratedAt) {
+		t.Fatalf("unexpected checkpoint: %+v, generated_at=%s", checkpoint, report.GeneratedAt)
+	}
+	opts, err := r.options(db, 0)
+	if err != nil || !opts.Since.Equal(report.GeneratedAt) || opts.FromBlock != 101 {
+		t.Fatalf("unexpected options: %+v, err=%v", opts, err)
+	}
+}
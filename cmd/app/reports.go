@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"task/cmd/app/apierr"
+	"task/cmd/app/auth"
+	"task/cmd/app/fees"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// registerReportRoutes 注册报表路由，需要 read-all 权限
+func registerReportRoutes(r *gin.Engine, db *gorm.DB) {
+	read := r.Group("/report", auth.Require(auth.PermReadAll))
+
+	// 手续费报表 (GET /report/gas-fees?from=2006-01-02&to=2006-01-02)
+	// 统计 [from, to) 之间上链的交易，日期按 UTC，默认最近 30 天
+	read.GET("/gas-fees", func(c *gin.Context) {
+		to := time.Now().UTC().Truncate(time.Hour*24).AddDate(0, 0, 1)
+		from := to.AddDate(0, 0, -30)
+		var err error
+		if v := c.Query("from"); v != "" {
+			from, err = time.Parse("2006-01-02", v)
+			if err != nil {
+				apierr.Respond(c, apierr.Field(apierr.CodeValidationFailed, "from", "must be a date like 2006-01-02", err))
+				return
+			}
+		}
+		if v := c.Query("to"); v != "" {
+			to, err = time.Parse("2006-01-02", v)
+			if err != nil {
+				apierr.Respond(c, apierr.Field(apierr.CodeValidationFailed, "to", "must be a date like 2006-01-02", err))
+				return
+			}
+		}
+		if !from.Before(to) {
+			apierr.Respond(c, apierr.Field(apierr.CodeValidationFailed, "to", "must be after from", nil))
+			return
+		}
+
+		report, err := fees.Build(db.WithContext(c.Request.Context()), from, to)
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("build gas fee report failed", err))
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	})
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"task/cmd/app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WithdrawalRepository 提款申请和审批记录的读写
+// 方法的 db 参数可以是调用方的事务，需要在同一个事务中完成的操作由调用方传入同一个 tx。
+type WithdrawalRepository interface {
+	// Create 创建提款申请
+	Create(db *gorm.DB, withdrawal *model.Withdrawal) error
+	// Lock 在事务内读取并锁定提款申请，直到事务提交或回滚；不存在时返回 gorm.ErrRecordNotFound
+	Lock(tx *gorm.DB, id uint64) (*model.Withdrawal, error)
+	// List 查询提款申请，按 ID 排序
+	List(db *gorm.DB, filter Filter) ([]*model.Withdrawal, error)
+	// Count 统计满足条件的提款申请数
+	Count(db *gorm.DB, filter Filter) (int64, error)
+	// Update 更新提款申请的字段，只有版本号和状态仍为 withdrawal.Version 和 from 时才更新，否则返回 ErrConflict
+	// 更新成功后版本号加 1，withdrawal.Version 同步更新；调用方可以在调用前修改 withdrawal 中的字段。
+	Update(db *gorm.DB, withdrawal *model.Withdrawal, from model.WithdrawalState, fields map[string]interface{}) error
+
+	// Confirmation 查询经理对提款申请的审批记录，没有时返回 nil
+	Confirmation(db *gorm.DB, withdrawalID, managerID uint64) (*model.WithdrawalConfirmation, error)
+	// Confirm 记录审批；已有审批记录时把审批时间更新为 at
+	Confirm(db *gorm.DB, withdrawalID, managerID uint64, at time.Time) error
+	// Revoke 删除审批记录，返回是否存在
+	Revoke(db *gorm.DB, withdrawalID, managerID uint64) (bool, error)
+	// CountApprovals 统计 since 之后的有效审批数，发起人的审批不计入；since 为零值时不限制
+	CountApprovals(db *gorm.DB, withdrawal *model.Withdrawal, since time.Time) (int64, error)
+}
+
+// ErrConflict 提款申请已被其他请求修改，重新读取后可以重试
+var ErrConflict = errors.New("withdrawal modified concurrently")
+
+// Filter 提款申请查询条件，零值表示不限制
+type Filter struct {
+	ID        uint64
+	CreatedBy uint64
+}
+
+// New 按数据库类型创建
+func New(db *gorm.DB) WithdrawalRepository {
+	if db.Dialector.Name() == model.DriverSQLite {
+		return &sqliteRepository{}
+	}
+	return &postgresRepository{}
+}
+
+// postgresRepository 使用 SELECT ... FOR UPDATE 行锁
+type postgresRepository struct {
+	gormRepository
+}
+
+func (r *postgresRepository) Lock(tx *gorm.DB, id uint64) (*model.Withdrawal, error) {
+	return r.first(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id)
+}
+
+// sqliteRepository SQLite 没有行锁，同一时间只有一个写事务
+// 连接使用 _txlock=immediate，事务开始时即持有写锁，读取到的就是最新数据，效果与行锁相同（粒度为整个数据库）。
+type sqliteRepository struct {
+	gormRepository
+}
+
+func (r *sqliteRepository) Lock(tx *gorm.DB, id uint64) (*model.Withdrawal, error) {
+	return r.first(tx, id)
+}
+
+// gormRepository 两种数据库通用的实现
+type gormRepository struct{}
+
+func (gormRepository) first(db *gorm.DB, id uint64) (*model.Withdrawal, error) {
+	withdrawal := &model.Withdrawal{}
+	err := db.Where("id = ?", id).First(withdrawal).Error
+	if err != nil {
+		return nil, err
+	}
+	return withdrawal, nil
+}
+
+func (gormRepository) Create(db *gorm.DB, withdrawal *model.Withdrawal) error {
+	return db.Create(withdrawal).Error
+}
+
+func (gormRepository) query(db *gorm.DB, filter Filter) *gorm.DB {
+	query := db.Model(&model.Withdrawal{})
+	if filter.ID != 0 {
+		query = query.Where("id = ?", filter.ID)
+	}
+	if filter.CreatedBy != 0 {
+		query = query.Where("created_by = ?", filter.CreatedBy)
+	}
+	return query
+}
+
+func (r gormRepository) List(db *gorm.DB, filter Filter) ([]*model.Withdrawal, error) {
+	var withdrawals []*model.Withdrawal
+	err := r.query(db, filter).Order("id").Find(&withdrawals).Error
+	return withdrawals, err
+}
+
+func (r gormRepository) Count(db *gorm.DB, filter Filter) (int64, error) {
+	var count int64
+	err := r.query(db, filter).Count(&count).Error
+	return count, err
+}
+
+func (gormRepository) Update(db *gorm.DB, withdrawal *model.Withdrawal, from model.WithdrawalState, fields map[string]interface{}) error {
+	updates := make(map[string]interface{}, len(fields)+1)
+	for k, v := range fields {
+		updates[k] = v
+	}
+	updates["version"] = gorm.Expr("version + 1")
+
+	result := db.Model(&model.Withdrawal{}).
+		Where("id = ? AND version = ? AND status = ?", withdrawal.ID, withdrawal.Version, uint64(from)).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrConflict
+	}
+	withdrawal.Version++
+	return nil
+}
+
+func (gormRepository) Confirmation(db *gorm.DB, withdrawalID, managerID uint64) (*model.WithdrawalConfirmation, error) {
+	// 不存在是正常情况，使用 Find 避免 gorm 记录 record not found 日志
+	confirmation := &model.WithdrawalConfirmation{}
+	err := db.Where("withdrawal_id = ? AND manager_id = ?", withdrawalID, managerID).
+		Limit(1).
+		Find(confirmation).Error
+	if err != nil || confirmation.ID == 0 {
+		return nil, err
+	}
+	return confirmation, nil
+}
+
+func (r gormRepository) Confirm(db *gorm.DB, withdrawalID, managerID uint64, at time.Time) error {
+	confirmation, err := r.Confirmation(db, withdrawalID, managerID)
+	if err != nil {
+		return err
+	}
+	if confirmation != nil {
+		return db.Model(confirmation).Update("created_at", at).Error
+	}
+	return db.Create(&model.WithdrawalConfirmation{
+		CreatedAt:    at,
+		WithdrawalID: withdrawalID,
+		ManagerID:    managerID,
+	}).Error
+}
+
+func (gormRepository) Revoke(db *gorm.DB, withdrawalID, managerID uint64) (bool, error) {
+	result := db.Where("withdrawal_id = ? AND manager_id = ?", withdrawalID, managerID).
+		Delete(&model.WithdrawalConfirmation{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (gormRepository) CountApprovals(db *gorm.DB, withdrawal *model.Withdrawal, since time.Time) (int64, error) {
+	query := db.Model(&model.WithdrawalConfirmation{}).
+		Where("withdrawal_id = ?", withdrawal.ID).
+		Where("manager_id != ?", withdrawal.CreatedBy)
+	if !since.IsZero() {
+		query = query.Where("created_at >= ?", since)
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
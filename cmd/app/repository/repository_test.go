@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"task/cmd/app/migrate"
+	"task/cmd/app/model"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+func openSQLite(t *testing.T) *gorm.DB {
+	db, err := model.Open(model.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.New(db)
+	if err == nil {
+		_, err = m.Up(context.Background())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestApprovals(t *testing.T) {
+	db := openSQLite(t)
+	repo := New(db)
+	if _, ok := repo.(*sqliteRepository); !ok {
+		t.Fatalf("expected sqlite repository, got %T", repo)
+	}
+
+	withdrawal := &model.Withdrawal{Amount: decimal.NewFromInt(1), CreatedBy: 1}
+	if err := repo.Create(db, withdrawal); err != nil {
+		t.Fatal(err)
+	}
+	id := uint64(withdrawal.ID)
+
+	now := time.Now()
+	for _, managerID := range []uint64{1, 2, 3} {
+		if err := repo.Confirm(db, id, managerID, now.Add(-time.Hour*2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 重新审批只更新审批时间
+	if err := repo.Confirm(db, id, 3, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// 发起人的审批不计入
+	count, err := repo.CountApprovals(db, withdrawal, time.Time{})
+	if err != nil || count != 2 {
+		t.Fatalf("expected 2 approvals, got %d, err=%v", count, err)
+	}
+	count, err = repo.CountApprovals(db, withdrawal, now.Add(-time.Hour))
+	if err != nil || count != 1 {
+		t.Fatalf("expected 1 recent approval, got %d, err=%v", count, err)
+	}
+
+	revoked, err := repo.Revoke(db, id, 2)
+	if err != nil || !revoked {
+		t.Fatalf("expected revoked, got %t, err=%v", revoked, err)
+	}
+	revoked, err = repo.Revoke(db, id, 2)
+	if err != nil || revoked {
+		t.Fatalf("expected nothing to revoke, got %t, err=%v", revoked, err)
+	}
+	confirmation, err := repo.Confirmation(db, id, 2)
+	if err != nil || confirmation != nil {
+		t.Fatalf("expected no confirmation, got %+v, err=%v", confirmation, err)
+	}
+}
+
+func TestList(t *testing.T) {
+	db := openSQLite(t)
+	repo := New(db)
+
+	for _, createdBy := range []uint64{1, 2, 1} {
+		err := repo.Create(db, &model.Withdrawal{Amount: decimal.NewFromInt(1), CreatedBy: createdBy})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	withdrawals, err := repo.List(db, Filter{CreatedBy: 1})
+	if err != nil || len(withdrawals) != 2 || withdrawals[0].ID > withdrawals[1].ID {
+		t.Fatalf("unexpected withdrawals: %+v, err=%v", withdrawals, err)
+	}
+	count, err := repo.Count(db, Filter{ID: 2, CreatedBy: 1})
+	if err != nil || count != 0 {
+		t.Fatalf("expected 0, got %d, err=%v", count, err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		withdrawal, err := repo.Lock(tx, 2)
+		if err != nil {
+			return err
+		}
+		return repo.Update(tx, withdrawal, model.StateUnchained, map[string]interface{}{"status": model.StateRejected})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withdrawals, err = repo.List(db, Filter{ID: 2})
+	if err != nil || len(withdrawals) != 1 || withdrawals[0].Status != uint64(model.StateRejected) || withdrawals[0].Version != 1 {
+		t.Fatalf("unexpected withdrawals: %+v, err=%v", withdrawals, err)
+	}
+}
+
+func TestUpdateConflict(t *testing.T) {
+	db := openSQLite(t)
+	repo := New(db)
+
+	err := repo.Create(db, &model.Withdrawal{Amount: decimal.NewFromInt(1), CreatedBy: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := repo.Lock(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := *first
+
+	// 第一次更新成功，版本号加 1
+	err = repo.Update(db, first, model.StateUnchained, map[string]interface{}{"status": model.StatePending, "tx_hash": "0x1"})
+	if err != nil || first.Version != 1 {
+		t.Fatalf("expected version 1, got %d, err=%v", first.Version, err)
+	}
+
+	// 使用旧版本号更新返回 ErrConflict，不覆盖已有的更新
+	err = repo.Update(db, &stale, model.StateUnchained, map[string]interface{}{"status": model.StateRejected})
+	if !errors.Is(err, ErrConflict) || stale.Version != 0 {
+		t.Fatalf("expected conflict, got version %d, err=%v", stale.Version, err)
+	}
+	// 版本号一致但状态不是预期的状态同样返回 ErrConflict
+	err = repo.Update(db, first, model.StateUnchained, map[string]interface{}{"status": model.StateRejected})
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflict, got err=%v", err)
+	}
+
+	withdrawals, err := repo.List(db, Filter{ID: 1})
+	if err != nil || len(withdrawals) != 1 {
+		t.Fatalf("unexpected withdrawals: %+v, err=%v", withdrawals, err)
+	}
+	if w := withdrawals[0]; w.Status != uint64(model.StatePending) || w.TxHash != "0x1" || w.Version != 1 {
+		t.Fatalf("unexpected withdrawal: %+v", w)
+	}
+}
@@ -0,0 +1,45 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header 请求 ID 请求头，调用方传入时沿用，否则生成；响应中总是返回
+const Header = "X-Request-ID"
+
+type contextKey struct{}
+
+// New 生成请求 ID
+func New() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Middleware 为每个请求确定请求 ID，写入响应头和请求的 context
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(Header)
+		if id == "" || len(id) > 128 {
+			id = New()
+		}
+		c.Header(Header, id)
+		c.Request = c.Request.WithContext(With(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// With 返回带请求 ID 的 context
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// From 获取 context 中的请求 ID，没有时返回空字符串
+func From(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
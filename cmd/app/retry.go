@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy 重试策略
+// attempt 为即将进行的第几次重试（从 1 开始），elapsed 为该阶段自第一次尝试起已经过去的时间。
+// 返回下一次重试前需要等待的时间，ok 为 false 表示不再重试。
+type RetryPolicy interface {
+	NextBackoff(attempt int, elapsed time.Duration) (wait time.Duration, ok bool)
+}
+
+// ConstantBackoff 固定间隔重试
+type ConstantBackoff struct {
+	Interval   time.Duration // 重试间隔
+	MaxRetries int           // 最大重试次数，<= 0 表示不限制
+}
+
+func (p ConstantBackoff) NextBackoff(attempt int, _ time.Duration) (time.Duration, bool) {
+	if p.MaxRetries > 0 && attempt > p.MaxRetries {
+		return 0, false
+	}
+	return p.Interval, true
+}
+
+// ExponentialBackoff 指数退避重试，带随机抖动
+// 第 n 次重试的基础等待时间为 Initial * Multiplier^(n-1)，不超过 Max，
+// 再在 [base*(1-Jitter), base*(1+Jitter)] 区间内随机取值，避免多个实例同时重试。
+// 不限制 Max 时，等待时间最多为 time.Duration 能表示的最大值，不会溢出。
+type ExponentialBackoff struct {
+	Initial    time.Duration // 首次重试间隔
+	Max        time.Duration // 单次最大间隔，<= 0 表示不限制
+	Multiplier float64       // 增长倍数，<= 1 时按 2 处理
+	Jitter     float64       // 抖动比例，取值 [0, 1]
+	MaxRetries int           // 最大重试次数，<= 0 表示不限制
+}
+
+func (p ExponentialBackoff) NextBackoff(attempt int, _ time.Duration) (time.Duration, bool) {
+	if p.MaxRetries > 0 && attempt > p.MaxRetries {
+		return 0, false
+	}
+	if p.Initial <= 0 {
+		return 0, true
+	}
+
+	multiplier := p.Multiplier
+	if multiplier <= 1 {
+		multiplier = 2
+	}
+	base := float64(p.Initial) * math.Pow(multiplier, float64(attempt-1))
+	if p.Max > 0 && base > float64(p.Max) {
+		base = float64(p.Max)
+	}
+
+	jitter := p.Jitter
+	if jitter < 0 {
+		jitter = 0
+	} else if jitter > 1 {
+		jitter = 1
+	}
+	wait := base * (1 - jitter + 2*jitter*rand.Float64())
+	if wait >= math.MaxInt64 {
+		return math.MaxInt64, true
+	}
+
+	return time.Duration(wait), true
+}
+
+// MaxElapsedTime 在另一个策略的基础上限制总耗时
+// 下一次重试的时间点超过 MaxElapsed 时不再重试。
+type MaxElapsedTime struct {
+	Policy     RetryPolicy
+	MaxElapsed time.Duration
+}
+
+func (p MaxElapsedTime) NextBackoff(attempt int, elapsed time.Duration) (time.Duration, bool) {
+	wait, ok := p.Policy.NextBackoff(attempt, elapsed)
+	if !ok {
+		return 0, false
+	}
+	if p.MaxElapsed > 0 && elapsed+wait > p.MaxElapsed {
+		return 0, false
+	}
+	return wait, true
+}
+
+// DefaultSendRetryPolicy 上链请求失败时的默认重试策略
+func DefaultSendRetryPolicy() ExponentialBackoff {
+	return ExponentialBackoff{
+		Initial:    time.Second,
+		Max:        time.Second * 10,
+		Multiplier: 2,
+		Jitter:     0.2,
+		MaxRetries: 3,
+	}
+}
+
+// DefaultReceiptRetryPolicy 查询 receipt 的默认重试策略
+// 出块慢时交易可能长时间查不到 receipt，因此按总耗时而不是次数限制；查询期间不持有数据库事务和行锁。
+func DefaultReceiptRetryPolicy() MaxElapsedTime {
+	return MaxElapsedTime{
+		Policy: ExponentialBackoff{
+			Initial:    time.Second,
+			Max:        time.Second * 15,
+			Multiplier: 1.5,
+			Jitter:     0.2,
+		},
+		MaxElapsed: time.Minute * 2,
+	}
+}
+
+// DefaultWebhookRetryPolicy webhook 投递失败时的默认重试策略，约 1 天内重试 10 次
+func DefaultWebhookRetryPolicy() RetryPolicy {
+	return ExponentialBackoff{
+		Initial:    time.Second * 10,
+		Max:        time.Hour * 6,
+		Multiplier: 3,
+		Jitter:     0.2,
+		MaxRetries: 10,
+	}
+}
+
+// sleepContext 等待 d，ctx 取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
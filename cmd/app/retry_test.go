@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConstantBackoff(t *testing.T) {
+	p := ConstantBackoff{Interval: time.Second, MaxRetries: 2}
+	for attempt := 1; attempt <= 2; attempt++ {
+		wait, ok := p.NextBackoff(attempt, 0)
+		if !ok || wait != time.Second {
+			t.Fatalf("attempt %d: wait=%v ok=%v", attempt, wait, ok)
+		}
+	}
+	if _, ok := p.NextBackoff(3, 0); ok {
+		t.Fatalf("attempt 3 should not be retried")
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	p := ExponentialBackoff{Initial: time.Second, Max: time.Second * 5, Multiplier: 2}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		wait, ok := p.NextBackoff(i+1, 0)
+		if !ok || wait != w {
+			t.Fatalf("attempt %d: wait=%v ok=%v, want %v", i+1, wait, ok, w)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		wait, _ := p.NextBackoff(2, 0)
+		if wait < time.Second || wait > 3*time.Second {
+			t.Fatalf("jittered wait out of range: %v", wait)
+		}
+	}
+}
+
+func TestExponentialBackoffUnbounded(t *testing.T) {
+	p := ExponentialBackoff{Initial: time.Second, Multiplier: 2, Jitter: 0.2}
+	prev := time.Duration(0)
+	for attempt := 1; attempt <= 1000; attempt++ {
+		wait, ok := p.NextBackoff(attempt, 0)
+		if !ok || wait <= 0 {
+			t.Fatalf("attempt %d: wait=%v ok=%v", attempt, wait, ok)
+		}
+		if attempt > 40 && wait < prev/2 {
+			t.Fatalf("attempt %d: wait dropped from %v to %v", attempt, prev, wait)
+		}
+		prev = wait
+	}
+}
+
+func TestMaxElapsedTime(t *testing.T) {
+	p := MaxElapsedTime{
+		Policy:     ConstantBackoff{Interval: time.Second},
+		MaxElapsed: time.Second * 10,
+	}
+	if _, ok := p.NextBackoff(100, time.Second*8); !ok {
+		t.Fatalf("should retry before deadline")
+	}
+	if _, ok := p.NextBackoff(1, time.Second*9+time.Millisecond); ok {
+		t.Fatalf("should not retry past deadline")
+	}
+}
+
+func TestSleepContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if err := sleepContext(ctx, time.Minute); err != context.Canceled {
+		t.Fatalf("err=%v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("sleepContext ignored cancellation")
+	}
+}
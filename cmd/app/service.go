@@ -0,0 +1,565 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"task/cmd/app/apierr"
+	"task/cmd/app/assets"
+	"task/cmd/app/auth"
+	"task/cmd/app/eth"
+	"task/cmd/app/events"
+	"task/cmd/app/ledger"
+	"task/cmd/app/logging"
+	"task/cmd/app/metrics"
+	"task/cmd/app/model"
+	"task/cmd/app/repository"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// WithdrawalService 提款申请的业务逻辑，REST 和 gRPC 共用
+// 权限由各自的接口层校验，这里只处理与身份相关的业务规则（职责分离、只能查看自己发起的申请等）。
+type WithdrawalService struct {
+	d *dependencies
+}
+
+func newWithdrawalService(d *dependencies) *WithdrawalService {
+	return &WithdrawalService{d: d}
+}
+
+// stateMachineOptions 状态机配置：记录状态变化，使用配置的重试策略
+func (s *WithdrawalService) stateMachineOptions() []StateMachineOption {
+	opts := []StateMachineOption{WithRecorder(s.d.events)}
+	if s.d.sendRetry != nil {
+		opts = append(opts, WithSendRetryPolicy(s.d.sendRetry))
+	}
+	if s.d.receiptRetry != nil {
+		opts = append(opts, WithReceiptRetryPolicy(s.d.receiptRetry))
+	}
+	return opts
+}
+
+// Create 创建提款申请
+func (s *WithdrawalService) Create(ctx context.Context, identity *auth.Identity, req *WithdrawalRequest) (*model.Withdrawal, error) {
+	withdrawal, err := s.create(ctx, identity, req)
+	metrics.WithdrawalsCreated.WithLabelValues(outcome(err, "created")).Inc()
+	return withdrawal, err
+}
+
+func (s *WithdrawalService) create(ctx context.Context, identity *auth.Identity, req *WithdrawalRequest) (*model.Withdrawal, error) {
+	asset, err := assets.Lookup(req.Asset)
+	if err != nil {
+		return nil, apierr.Field(apierr.CodeValidationFailed, "asset", "unsupported asset", err)
+	}
+
+	// 金额必须大于 0、不超过上限，且能精确转换为最小单位
+	amount, base, err := asset.ParseAmount(req.Amount)
+	if err != nil {
+		return nil, apierr.Field(apierr.CodeInvalidAmount, "amount", err.Error(), err)
+	}
+
+	// 收款地址
+	to := req.To
+	if to == "" {
+		to = eth.To
+	}
+	toAddr, err := eth.ParseAddress(to)
+	if err != nil {
+		return nil, apierr.Field(apierr.CodeInvalidAddress, "to", "must be a hex address with a valid checksum", err)
+	}
+
+	slog.InfoContext(ctx, "create withdrawal", "user_id", identity.UserID, "amount", req.Amount, "to", req.To, "asset", req.Asset)
+	// 创建入库
+	withdrawal := &model.Withdrawal{
+		Amount:     amount,
+		AmountBase: decimal.NewFromBigInt(base, 0),
+		Asset:      asset.Symbol,
+		CreatedBy:  identity.UserID,
+		ToAddress:  toAddr.String(),
+	}
+
+	// 校验收款地址：黑名单拒绝，非白名单需要额外审批或拒绝
+	db := s.d.db.WithContext(ctx)
+	withdrawal.RequiredApprovals, err = s.d.addresses.Check(db, withdrawal.ToAddress)
+	if err != nil {
+		return nil, destinationError(err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 校验限额，与插入在同一事务中，并发的创建请求依次统计
+		if err := s.d.limits.Check(tx, withdrawal); err != nil {
+			return limitError(err)
+		}
+		if err := s.d.withdrawals.Create(tx, withdrawal); err != nil {
+			return apierr.Internal("create withdrawal failed", err)
+		}
+		// 从发起人可用余额预留提款金额
+		if err := ledger.Reserve(tx, withdrawal); err != nil {
+			return apierr.Internal("create withdrawal failed", err)
+		}
+		if _, err := s.d.events.Record(tx, withdrawal, events.TypeCreated, nil); err != nil {
+			return apierr.Internal("create withdrawal failed", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return withdrawal, nil
+}
+
+// List 查询提款申请，id 为 0 时查询所有；没有 read-all 权限只能查询自己发起的
+func (s *WithdrawalService) List(ctx context.Context, identity *auth.Identity, id uint64) ([]*model.Withdrawal, error) {
+	withdrawals, err := s.d.withdrawals.List(s.d.db.WithContext(ctx), s.visible(identity, id))
+	if err != nil {
+		return nil, apierr.Internal("find withdrawal failed", err)
+	}
+	return withdrawals, nil
+}
+
+// visible 调用方可以查看的提款申请
+func (s *WithdrawalService) visible(identity *auth.Identity, id uint64) repository.Filter {
+	filter := repository.Filter{ID: id}
+	if !identity.Can(auth.PermReadAll) {
+		filter.CreatedBy = identity.UserID
+	}
+	return filter
+}
+
+// ApprovalResult 审批或撤销审批的结果
+type ApprovalResult struct {
+	Withdrawal      *model.Withdrawal
+	Executed        bool  // 本次审批是否执行了提款
+	AlreadyApproved bool  // 该经理之前已经审批过，本次审批没有任何变化
+	Approvals       int64 // 当前有效审批数
+}
+
+// Approve 审批提款申请，达到所需审批数时自动执行
+// 重复审批是幂等的，返回 AlreadyApproved，不会再次触发执行。
+func (s *WithdrawalService) Approve(ctx context.Context, identity *auth.Identity, id uint64) (*ApprovalResult, error) {
+	result, err := s.approve(ctx, identity, id)
+	label := "approved"
+	if result != nil && result.AlreadyApproved {
+		label = "already_approved"
+	} else if result != nil && result.Executed {
+		label = "executed"
+	}
+	metrics.WithdrawalsApproved.WithLabelValues(outcome(err, label)).Inc()
+	return result, err
+}
+
+func (s *WithdrawalService) approve(ctx context.Context, identity *auth.Identity, id uint64) (*ApprovalResult, error) {
+	// 审批人取自认证身份，不信任请求体
+	mangerID := identity.UserID
+	ctx = logging.With(ctx, "withdrawal_id", id)
+	slog.InfoContext(ctx, "approve withdrawal", "manager_id", mangerID)
+	if id == 0 || mangerID <= 0 {
+		return nil, apierr.InvalidRequest(nil)
+	}
+
+	// 达到所需审批数时自动执行提款
+	// 开启事务，并对提款申请加行锁（SELECT ... FOR UPDATE），校验通过后提交，再由状态机执行。
+	// 并发的审批、执行请求在此排队；状态机签名时再次加行锁并校验版本号，只有一个请求能签名上链。
+	// 事务不随请求取消而回滚，客户端断开时已保存的审批记录仍然提交。
+	tx := s.d.db.WithContext(context.WithoutCancel(ctx)).Begin()
+
+	// 查询是否存在
+	withdrawal, err := s.d.withdrawals.Lock(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, findWithdrawalError(err)
+	}
+
+	if withdrawal.Status == uint64(model.StateRejected) {
+		tx.Rollback()
+		return nil, apierr.New(apierr.CodeWithdrawalRejected, "withdrawal rejected")
+	}
+
+	// 职责分离：发起人不能审批自己的提款申请
+	if withdrawal.CreatedBy == mangerID {
+		tx.Rollback()
+		return nil, apierr.New(apierr.CodeSelfApproval, "creator cannot approve own withdrawal")
+	}
+
+	if s.expired(withdrawal) {
+		tx.Rollback()
+		return nil, apierr.New(apierr.CodeWithdrawalExpired, "withdrawal expired")
+	}
+
+	// 同一经理重复审批时直接返回当前审批数；之前的审批已过有效期时重新计时
+	now := time.Now()
+	confirmation, err := s.d.withdrawals.Confirmation(tx, uint64(withdrawal.ID), mangerID)
+	if err != nil {
+		tx.Rollback()
+		return nil, apierr.Internal("find withdrawal confirmation failed", err)
+	}
+	if confirmation != nil && s.d.expiry.ApprovalValid(confirmation, now) {
+		count, err := s.countApprovals(tx, withdrawal)
+		tx.Rollback()
+		if err != nil {
+			return nil, apierr.Internal("count withdrawal confirmation failed", err)
+		}
+		return &ApprovalResult{Withdrawal: withdrawal, AlreadyApproved: true, Approvals: count}, nil
+	}
+
+	// 插入审批记录
+	err = s.d.withdrawals.Confirm(tx, uint64(withdrawal.ID), mangerID, now)
+	if err != nil {
+		tx.Rollback()
+		return nil, apierr.Internal("create withdrawal confirmation failed", err)
+	}
+
+	// 查询审批记录是否达到所需审批数
+	count, err := s.countApprovals(tx, withdrawal)
+	if err != nil {
+		tx.Rollback()
+		return nil, apierr.Internal("count withdrawal confirmation failed", err)
+	}
+	_, err = s.d.events.Record(tx, withdrawal, events.TypeApproved, map[string]interface{}{
+		"manager_id":         mangerID,
+		"approvals":          count,
+		"required_approvals": withdrawal.RequiredApprovals,
+	})
+	if err != nil {
+		tx.Rollback()
+		return nil, apierr.Internal("create withdrawal confirmation failed", err)
+	}
+
+	// 执行前重新校验收款地址，创建后加入黑名单的地址不再执行
+	err = s.checkDestination(tx, withdrawal)
+	if err != nil {
+		return nil, commit(tx, destinationError(err))
+	}
+
+	// 未达到所需审批数，直接返回
+	if count < int64(withdrawal.RequiredApprovals) {
+		if err = commit(tx, nil); err != nil {
+			return nil, err
+		}
+		return &ApprovalResult{Withdrawal: withdrawal, Approvals: count}, nil
+	}
+
+	// 达到所需审批数，自动执行提款
+	// 封装状态机，自动执行提款流程
+	// 如果有 tx hash，则不做任何操作，直接返回。
+	// 检查状态是否符合预期
+	if withdrawal.TxHash != "" {
+		if withdrawal.Status != uint64(model.StateUnchained) {
+			slog.WarnContext(ctx, "invalid status", "tx_hash", withdrawal.TxHash, "status", model.WithdrawalState(withdrawal.Status).String())
+		}
+		if err = commit(tx, nil); err != nil {
+			return nil, err
+		}
+		return &ApprovalResult{Withdrawal: withdrawal, Approvals: count}, nil
+	}
+
+	if !(withdrawal.TxHash == "" && withdrawal.Status == uint64(model.StateUnchained)) {
+		slog.WarnContext(ctx, "invalid status", "tx_hash", withdrawal.TxHash, "status", model.WithdrawalState(withdrawal.Status).String())
+		if err = commit(tx, nil); err != nil {
+			return nil, err
+		}
+		return &ApprovalResult{Withdrawal: withdrawal, Approvals: count}, nil
+	}
+
+	// 执行前再次校验限额，超出时保留审批记录，不执行
+	err = s.d.limits.Check(tx, withdrawal)
+	if err != nil {
+		return nil, commit(tx, limitError(err))
+	}
+
+	// 提交审批记录，释放行锁和限额的锁，上链和查询 receipt 期间不持有事务
+	if err = commit(tx, nil); err != nil {
+		return nil, err
+	}
+	metrics.TimeToApproval.Observe(time.Since(withdrawal.CreatedAt).Seconds())
+
+	// 执行提款
+	sm := NewStateMachine(withdrawal, s.d.client, s.d.relay, s.d.db, s.stateMachineOptions()...)
+	err = sm.Execute(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "execute withdrawal interrupted", "tx_hash", withdrawal.TxHash, "err", err)
+	}
+
+	// 被其他请求修改时由调用方重试，已提交到 outbox 的交易会被重新广播
+	if errors.Is(err, repository.ErrConflict) {
+		return nil, apierr.ConcurrentUpdate(err)
+	}
+	return &ApprovalResult{Withdrawal: withdrawal, Executed: true, Approvals: count}, nil
+}
+
+// RevokeApproval 撤销经理对尚未上链的提款申请的审批，返回撤销后的有效审批数
+func (s *WithdrawalService) RevokeApproval(ctx context.Context, identity *auth.Identity, id uint64) (*ApprovalResult, error) {
+	if id == 0 {
+		return nil, apierr.InvalidRequest(nil)
+	}
+
+	result := &ApprovalResult{}
+	err := s.d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 与审批、执行共用行锁，撤销后不会被并发的执行请求计入
+		withdrawal, err := s.d.withdrawals.Lock(tx, id)
+		if err != nil {
+			return findWithdrawalError(err)
+		}
+		result.Withdrawal = withdrawal
+		if withdrawal.Status == uint64(model.StateRejected) {
+			return apierr.New(apierr.CodeWithdrawalRejected, "withdrawal rejected")
+		}
+		if withdrawal.Status == uint64(model.StateExpired) {
+			return apierr.New(apierr.CodeWithdrawalExpired, "withdrawal expired")
+		}
+		if withdrawal.TxHash != "" || withdrawal.Status != uint64(model.StateUnchained) {
+			return apierr.New(apierr.CodeInvalidState, "approval cannot be revoked after execution").
+				WithMeta("state", model.WithdrawalState(withdrawal.Status).String())
+		}
+
+		revoked, err := s.d.withdrawals.Revoke(tx, uint64(withdrawal.ID), identity.UserID)
+		if err != nil {
+			return apierr.Internal("delete withdrawal confirmation failed", err)
+		}
+		if !revoked {
+			return apierr.New(apierr.CodeApprovalNotFound, "withdrawal not approved by this manager")
+		}
+
+		result.Approvals, err = s.countApprovals(tx, withdrawal)
+		if err != nil {
+			return apierr.Internal("count withdrawal confirmation failed", err)
+		}
+		_, err = s.d.events.Record(tx, withdrawal, events.TypeApprovalRevoked, map[string]interface{}{
+			"manager_id":         identity.UserID,
+			"approvals":          result.Approvals,
+			"required_approvals": withdrawal.RequiredApprovals,
+		})
+		if err != nil {
+			return apierr.Internal("revoke approval failed", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Reject 拒绝尚未上链的提款申请，拒绝后不能再审批或执行
+func (s *WithdrawalService) Reject(ctx context.Context, identity *auth.Identity, id uint64, reason string) (*model.Withdrawal, error) {
+	if id == 0 {
+		return nil, apierr.InvalidRequest(nil)
+	}
+
+	var withdrawal *model.Withdrawal
+	err := s.d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		withdrawal, err = s.d.withdrawals.Lock(tx, id)
+		if err != nil {
+			return findWithdrawalError(err)
+		}
+		if withdrawal.Status == uint64(model.StateRejected) {
+			return apierr.New(apierr.CodeWithdrawalRejected, "withdrawal rejected")
+		}
+		if withdrawal.Status == uint64(model.StateExpired) {
+			return apierr.New(apierr.CodeWithdrawalExpired, "withdrawal expired")
+		}
+		if withdrawal.TxHash != "" || withdrawal.Status != uint64(model.StateUnchained) {
+			return apierr.New(apierr.CodeInvalidState, "withdrawal cannot be rejected after execution").
+				WithMeta("state", model.WithdrawalState(withdrawal.Status).String())
+		}
+
+		withdrawal.Status = uint64(model.StateRejected)
+		err = s.d.withdrawals.Update(tx, withdrawal, model.StateUnchained, map[string]interface{}{"status": withdrawal.Status})
+		if err != nil {
+			return updateError("reject withdrawal failed", err)
+		}
+		err = ledger.Release(tx, withdrawal, model.StateRejected.String())
+		if err != nil {
+			return apierr.Internal("reject withdrawal failed", err)
+		}
+		_, err = s.d.events.Record(tx, withdrawal, events.TypeStateChanged, map[string]interface{}{
+			"from":        model.StateUnchained.String(),
+			"to":          model.StateRejected.String(),
+			"rejected_by": identity.UserID,
+			"reason":      reason,
+		})
+		if err != nil {
+			return apierr.Internal("reject withdrawal failed", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return withdrawal, nil
+}
+
+// Execute 执行提款
+// 与审批自动执行共用同一个状态机：
+//
+//	a) 没有 tx hash，则发起上链请求
+//	b) 已有 tx hash，则从查询 receipt 开始，按状态机规则处理（失败、异常都会重试上链）
+func (s *WithdrawalService) Execute(ctx context.Context, identity *auth.Identity, id uint64) (*model.Withdrawal, error) {
+	// 每次打印余额
+	defer func() {
+		s.d.client.PrintBalance()
+	}()
+
+	ctx = logging.With(ctx, "withdrawal_id", id)
+	slog.InfoContext(ctx, "execute withdrawal", "user_id", identity.UserID)
+	if id == 0 {
+		return nil, apierr.InvalidRequest(nil)
+	}
+
+	// 加行锁，与审批自动执行、其他执行请求串行
+	tx := s.d.db.WithContext(context.WithoutCancel(ctx)).Begin()
+	// 查询是否存在，且状态不是已上链的
+	withdrawal, err := s.d.withdrawals.Lock(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, findWithdrawalError(err)
+	}
+
+	switch model.WithdrawalState(withdrawal.Status) {
+	case model.StateSuccess:
+		tx.Rollback()
+		return nil, apierr.New(apierr.CodeAlreadyExecuted, "withdrawal already executed").
+			WithMeta("tx_hash", withdrawal.TxHash)
+	case model.StateRejected:
+		tx.Rollback()
+		return nil, apierr.New(apierr.CodeWithdrawalRejected, "withdrawal rejected")
+	}
+	if s.expired(withdrawal) {
+		tx.Rollback()
+		return nil, apierr.New(apierr.CodeWithdrawalExpired, "withdrawal expired")
+	}
+
+	// 重新校验收款地址
+	err = s.checkDestination(tx, withdrawal)
+	if err != nil {
+		tx.Rollback()
+		return nil, destinationError(err)
+	}
+
+	// 查询审批记录是否达到所需审批数
+	count, err := s.countApprovals(tx, withdrawal)
+	if err != nil {
+		tx.Rollback()
+		return nil, apierr.Internal("count withdrawal confirmation failed", err)
+	}
+
+	// 未达到所需审批数，不允许提款
+	if count < int64(withdrawal.RequiredApprovals) {
+		tx.Rollback()
+		return nil, apierr.New(apierr.CodeInsufficientApprovals, "not enough approvals").
+			WithMeta("approvals", count).
+			WithMeta("required_approvals", withdrawal.RequiredApprovals)
+	}
+
+	// 执行前再次校验限额
+	err = s.d.limits.Check(tx, withdrawal)
+	if err != nil {
+		tx.Rollback()
+		return nil, limitError(err)
+	}
+
+	// 提交后释放行锁和限额的锁，状态机在各自的短事务中保存
+	if err = commit(tx, nil); err != nil {
+		return nil, err
+	}
+
+	// 执行提款
+	sm := NewStateMachine(withdrawal, s.d.client, s.d.relay, s.d.db, s.stateMachineOptions()...)
+	err = sm.Execute(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "execute withdrawal interrupted", "tx_hash", withdrawal.TxHash, "err", err)
+	}
+	if errors.Is(err, repository.ErrConflict) {
+		return nil, apierr.ConcurrentUpdate(err)
+	}
+	return withdrawal, nil
+}
+
+// CanWatch 校验调用方能否订阅事件，id 为 0 表示订阅所有提款申请，需要 read-all 权限
+func (s *WithdrawalService) CanWatch(identity *auth.Identity, id uint64) error {
+	if id == 0 {
+		if !identity.Can(auth.PermReadAll) {
+			return apierr.New(apierr.CodeForbidden, "forbidden")
+		}
+		return nil
+	}
+
+	count, err := s.d.withdrawals.Count(s.d.db, s.visible(identity, id))
+	if err != nil {
+		return apierr.Internal("find withdrawal failed", err)
+	}
+	if count == 0 {
+		return apierr.New(apierr.CodeWithdrawalNotFound, "withdrawal not found")
+	}
+	return nil
+}
+
+// outcome 指标中的结果标签，失败时为小写的错误码
+func outcome(err error, ok string) string {
+	if err != nil {
+		return strings.ToLower(string(apierr.From(err).Code))
+	}
+	return ok
+}
+
+// commit 提交事务后返回 err；提交失败时返回 INTERNAL，调用方不能把未保存的结果返回给客户端
+func commit(tx *gorm.DB, err error) error {
+	if commitErr := tx.Commit().Error; commitErr != nil {
+		return apierr.Internal("commit withdrawal failed", commitErr)
+	}
+	return err
+}
+
+// findWithdrawalError 查询提款申请失败，记录不存在时返回 WITHDRAWAL_NOT_FOUND
+func findWithdrawalError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apierr.Wrap(apierr.CodeWithdrawalNotFound, "withdrawal not found", err)
+	}
+	return apierr.Internal("find withdrawal failed", err)
+}
+
+// checkDestination 执行前重新校验收款地址
+// 黑名单地址返回 *addressbook.RejectedError；地址被移出白名单时提高所需审批数。
+func (s *WithdrawalService) checkDestination(tx *gorm.DB, withdrawal *model.Withdrawal) error {
+	to := withdrawal.ToAddress
+	if to == "" {
+		to = eth.To
+	}
+	required, err := s.d.addresses.Check(tx, to)
+	if err != nil {
+		return err
+	}
+	if required > withdrawal.RequiredApprovals {
+		withdrawal.RequiredApprovals = required
+		return s.d.withdrawals.Update(tx, withdrawal, model.WithdrawalState(withdrawal.Status), map[string]interface{}{"required_approvals": required})
+	}
+	return nil
+}
+
+// expired 提款申请已过期，或已超过有效期但还没有被后台标记
+// outbox 中已有签名交易时交易可能已经上链，不视为过期，交给状态机继续处理。
+func (s *WithdrawalService) expired(withdrawal *model.Withdrawal) bool {
+	if withdrawal.Status == uint64(model.StateExpired) {
+		return true
+	}
+	if !s.d.expiry.Expired(withdrawal, time.Now()) {
+		return false
+	}
+	entry, err := s.d.relay.Latest(uint64(withdrawal.ID))
+	if err != nil {
+		slog.Error("find outbox failed", "withdrawal_id", withdrawal.ID, "err", err)
+		return false
+	}
+	return entry == nil
+}
+
+// countApprovals 统计提款申请的有效审批数，发起人的审批和超过有效期的审批不计入
+func (s *WithdrawalService) countApprovals(tx *gorm.DB, withdrawal *model.Withdrawal) (int64, error) {
+	return s.d.withdrawals.CountApprovals(tx, withdrawal, s.d.expiry.ApprovalsSince(time.Now()))
+}
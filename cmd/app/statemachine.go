@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/big"
+	"time"
+
+	"task/cmd/app/eth"
+	"task/cmd/app/events"
+	"task/cmd/app/ledger"
+	"task/cmd/app/logging"
+	"task/cmd/app/metrics"
+	"task/cmd/app/model"
+	"task/cmd/app/outbox"
+	"task/cmd/app/repository"
+	"task/cmd/app/tracing"
+
+	"github.com/umbracle/ethgo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// TransactionState 交易状态
+type TransactionState uint8
+
+const (
+	StateUnchained TransactionState = iota // 未上链
+	StatePending                           // 上链中
+	StateSuccess                           // 上链成功
+	StateFailure                           // 上链失败
+	StateException                         // 其他异常情况
+)
+
+// String 状态名称，取值与 model.WithdrawalState 的前几个状态一致
+func (s TransactionState) String() string {
+	return model.WithdrawalState(s).String()
+}
+
+// TransactionEvent 触发状态转换的事件
+type TransactionEvent uint8
+
+const (
+	EventStart             TransactionEvent = iota // 开始处理
+	EventCheck                                     // 检查状态
+	EventRetry                                     // 重试事件
+	EventMaxRetriesReached                         // 达到最大重试次数
+	EventSuccess                                   // 上链成功
+	EventCanceled                                  // 上下文取消
+	EventAborted                                   // 保存提款申请失败，如已被其他请求修改
+)
+
+// String 事件名称，用于 span 名称
+func (e TransactionEvent) String() string {
+	switch e {
+	case EventStart:
+		return "start"
+	case EventCheck:
+		return "check"
+	case EventRetry:
+		return "retry"
+	case EventMaxRetriesReached:
+		return "max_retries_reached"
+	case EventSuccess:
+		return "success"
+	case EventCanceled:
+		return "canceled"
+	case EventAborted:
+		return "aborted"
+	default:
+		return "unknown"
+	}
+}
+
+// EthClient 状态机和接口依赖的链上操作，*eth.Client 实现了该接口
+type EthClient interface {
+	SignTransaction(ctx context.Context, to string, value *big.Int) (*eth.SignedTransaction, error)
+	SendRawTransactionContext(ctx context.Context, data []byte) (ethgo.Hash, error)
+	GetTransactionReceiptContext(ctx context.Context, hash ethgo.Hash) (*ethgo.Receipt, error)
+	PrintBalance()
+}
+
+var _ EthClient = (*eth.Client)(nil)
+
+type StateMachine struct {
+	state          TransactionState
+	sendPolicy     RetryPolicy // 上链请求失败、上链失败时的重试策略
+	receiptPolicy  RetryPolicy // 查询 receipt 的重试策略
+	sendRetries    int         // 上链请求已重试次数
+	receiptRetries int         // 查询 receipt 已重试次数（每次重新上链后清零）
+	sendStart      time.Time
+	receiptStart   time.Time
+	err            error
+	withdrawal     *model.Withdrawal
+	client         EthClient
+	relay          *outbox.Relay
+	db             *gorm.DB // 每次保存时在 db 上开启持有行锁的短事务
+	withdrawals    repository.WithdrawalRepository
+	events         *events.Recorder // 记录状态变化，为 nil 时不记录
+}
+
+// StateMachineOption 状态机配置项
+type StateMachineOption func(*StateMachine)
+
+// WithSendRetryPolicy 设置上链请求的重试策略
+func WithSendRetryPolicy(policy RetryPolicy) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.sendPolicy = policy
+	}
+}
+
+// WithReceiptRetryPolicy 设置查询 receipt 的重试策略
+func WithReceiptRetryPolicy(policy RetryPolicy) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.receiptPolicy = policy
+	}
+}
+
+// WithRecorder 记录每一次状态变化
+func WithRecorder(recorder *events.Recorder) StateMachineOption {
+	return func(sm *StateMachine) {
+		sm.events = recorder
+	}
+}
+
+func NewStateMachine(
+	withdrawal *model.Withdrawal,
+	client EthClient,
+	relay *outbox.Relay,
+	db *gorm.DB,
+	opts ...StateMachineOption,
+) *StateMachine {
+	sm := &StateMachine{
+		state:         StateUnchained,
+		sendPolicy:    DefaultSendRetryPolicy(),
+		receiptPolicy: DefaultReceiptRetryPolicy(),
+		withdrawal:    withdrawal,
+		client:        client,
+		relay:         relay,
+		db:            db,
+		withdrawals:   repository.New(db),
+	}
+	for _, opt := range opts {
+		opt(sm)
+	}
+	return sm
+}
+
+// Execute 执行状态机，直到成功、重试耗尽或 ctx 被取消
+// 没有 tx hash 时从发起上链请求开始，已有 tx hash 时从查询 receipt 开始。
+// ctx 被取消时立即返回 ctx.Err()，已保存的 tx hash 和状态保持不变，后续可以继续查询。
+// 提款申请已被其他请求修改时立即返回 repository.ErrConflict，不覆盖更新的状态。
+// 开始前在账本中预留提款金额，上链成功时结算，最终失败时释放。
+// 状态机不在调用方的事务中运行，每次保存都在持有行锁的短事务中完成（见 transaction），
+// 广播、重试等待和查询 receipt 期间不持有数据库事务和行锁。
+// 执行期间的日志都带有 ctx 中的请求 ID 和 withdrawal_id；整个执行和每次状态转换各是一个 span。
+func (sm *StateMachine) Execute(ctx context.Context) (err error) {
+	sm.sendStart = time.Now()
+	ctx = logging.With(ctx, "withdrawal_id", sm.withdrawal.ID)
+	ctx, span := tracing.Start(ctx, "statemachine.Execute",
+		trace.WithAttributes(tracing.Int64("withdrawal.id", uint64(sm.withdrawal.ID))))
+	defer func() {
+		span.SetAttributes(
+			attribute.String("withdrawal.status", model.WithdrawalState(sm.withdrawal.Status).String()),
+			attribute.String("withdrawal.tx_hash", sm.withdrawal.TxHash),
+			attribute.Int("statemachine.send_retries", sm.sendRetries),
+			attribute.Int("statemachine.receipt_retries", sm.receiptRetries),
+		)
+		tracing.End(span, err)
+	}()
+
+	// 上一次执行最终失败时预留已经释放，重新执行需要重新预留
+	err = sm.transaction(func(tx *gorm.DB) error {
+		return ledger.Reserve(tx, sm.withdrawal)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "reserve withdrawal funds failed", "err", err)
+		return err
+	}
+
+	event, ok := EventStart, true
+	if sm.withdrawal.TxHash != "" {
+		sm.state = StatePending
+		sm.receiptStart = sm.sendStart
+		event = EventCheck
+	}
+	for ok {
+		event, ok = sm.step(ctx, event)
+	}
+	sm.observe(event)
+	return sm.err
+}
+
+// step 执行一次状态转换，期间的数据库操作和链上调用都是该转换 span 的子 span
+func (sm *StateMachine) step(ctx context.Context, event TransactionEvent) (TransactionEvent, bool) {
+	ctx, span := tracing.Start(ctx, "statemachine."+event.String(),
+		trace.WithAttributes(attribute.String("statemachine.from", sm.state.String())))
+	// 数据库操作不随 ctx 取消：已广播的交易的 tx hash 和状态必须保存，ctx 中的 span 仍然关联
+	db := sm.db
+	sm.db = db.WithContext(context.WithoutCancel(ctx))
+	defer func() {
+		sm.db = db
+	}()
+
+	next, ok := sm.next(ctx, event)
+	span.SetAttributes(
+		attribute.String("statemachine.to", sm.state.String()),
+		attribute.String("statemachine.next_event", next.String()),
+	)
+	tracing.End(span, sm.err)
+	return next, ok
+}
+
+// observe 记录执行结果和重试次数
+func (sm *StateMachine) observe(event TransactionEvent) {
+	result := model.WithdrawalState(sm.withdrawal.Status).String()
+	switch event {
+	case EventCanceled:
+		result = "canceled"
+	case EventAborted:
+		result = "aborted"
+	}
+	metrics.WithdrawalsExecuted.WithLabelValues(result).Inc()
+	metrics.StateMachineRetries.WithLabelValues("send").Observe(float64(sm.sendRetries))
+	metrics.StateMachineRetries.WithLabelValues("receipt").Observe(float64(sm.receiptRetries))
+}
+
+// State 当前状态
+func (sm *StateMachine) State() TransactionState {
+	return sm.state
+}
+
+// 如果没有 tx hash，则是未上链，则发起上链请求，并保存 tx hash
+// 根据 tx hash 查询 receipt，根据 receipt 状态更新提款申请状态
+// 上链请求和查询 receipt 分别按各自的 RetryPolicy 重试
+//
+//	a) 如果获取不到 receipt，说明上链中，更新提款申请状态为上链中，按 receiptPolicy 退避后重新查询 receipt。
+//	   重试耗尽，receipt 仍然获取不到，则打印日志，状态为上链中。
+//	b) 如果 receipt 状态为成功, 则更新提款申请状态为上链成功。
+//	c) 如果 receipt 状态为失败, 打印日志，按 sendPolicy 退避后重新发起上链请求。
+//	   重试耗尽，receipt 仍然失败，则打印日志，状态为上链失败。
+//	d) 如果 receipt 状态为其他异常情况，打印日志，按 sendPolicy 退避后重新发起上链请求。
+//	   重试耗尽，receipt 仍然失败，则打印日志，状态为其他异常情况。
+//
+// 流程图：img.png
+//
+// 返回下一个事件，ok 为 false 表示状态机结束
+func (sm *StateMachine) next(ctx context.Context, event TransactionEvent) (TransactionEvent, bool) {
+	switch event {
+	case EventStart:
+		if ctx.Err() != nil {
+			return EventCanceled, true
+		}
+
+		// 签名并保存 tx hash 后发起上链请求，失败则重试
+		entry, err := sm.prepareTransaction(ctx)
+		if errors.Is(err, repository.ErrConflict) {
+			return sm.abort(err)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "prepare transaction failed", "attempt", sm.sendRetries+1, "err", err)
+			sm.state = StateUnchained
+			return EventRetry, true
+		}
+
+		err = sm.relay.Send(ctx, entry)
+		if err != nil {
+			slog.WarnContext(ctx, "send transaction failed", "tx_hash", entry.TxHash, "nonce", entry.Nonce,
+				"attempt", sm.sendRetries+1, "err", err)
+			sm.state = StateUnchained
+			return EventRetry, true
+		}
+
+		sm.state = StatePending
+		sm.receiptRetries = 0
+		sm.receiptStart = time.Now()
+		return EventCheck, true
+	case EventCheck:
+		if ctx.Err() != nil {
+			return EventCanceled, true
+		}
+
+		// 查询 receipt
+		receipt, err := sm.client.GetTransactionReceiptContext(ctx, ethgo.HexToHash(sm.withdrawal.TxHash))
+		if err != nil {
+			slog.WarnContext(ctx, "get transaction receipt failed", "tx_hash", sm.withdrawal.TxHash,
+				"attempt", sm.receiptRetries+1, "err", err)
+			sm.state = StatePending
+			return EventRetry, true
+		}
+		// mock pending
+		// receipt = nil
+		if receipt == nil {
+			sm.state = StatePending
+			_, err = sm.events.Record(sm.db, sm.withdrawal, events.TypeReceiptPending, map[string]interface{}{
+				"attempt": sm.receiptRetries + 1,
+			})
+			if err != nil {
+				slog.ErrorContext(ctx, "record withdrawal event failed", "tx_hash", sm.withdrawal.TxHash, "err", err)
+			}
+			return EventRetry, true
+		}
+
+		sm.recordGas(ctx, receipt)
+
+		// mock failure
+		// receipt.Status = 0
+		// mock exception
+		// receipt.Status = 2
+
+		if receipt.Status == 1 {
+			sm.state = StateSuccess
+			err := sm.transaction(func(tx *gorm.DB) error {
+				if err := sm.save(ctx, tx, sm.withdrawal.TxHash, model.StateSuccess); err != nil {
+					return err
+				}
+				return sm.settle(tx)
+			})
+			if err != nil {
+				return sm.abort(err)
+			}
+			metrics.TimeToMined.Observe(time.Since(sm.withdrawal.CreatedAt).Seconds())
+			return EventSuccess, true
+		} else if receipt.Status == 0 {
+			sm.state = StateFailure
+			if err := sm.updateWithdrawalStatus(ctx, model.StateFailure); err != nil {
+				return sm.abort(err)
+			}
+			return EventRetry, true
+		} else {
+			sm.state = StateException
+			if err := sm.updateWithdrawalStatus(ctx, model.StateException); err != nil {
+				return sm.abort(err)
+			}
+			return EventRetry, true
+		}
+	case EventRetry:
+		// 上链中按 receipt 策略重试，其余情况按上链策略重试
+		policy, retries, start := sm.sendPolicy, &sm.sendRetries, sm.sendStart
+		if sm.state == StatePending {
+			policy, retries, start = sm.receiptPolicy, &sm.receiptRetries, sm.receiptStart
+		}
+
+		wait, ok := policy.NextBackoff(*retries+1, time.Since(start))
+		if !ok {
+			return EventMaxRetriesReached, true
+		}
+		*retries++
+
+		if err := sleepContext(ctx, wait); err != nil {
+			return EventCanceled, true
+		}
+
+		// 重试，根据状态跳转
+		if sm.state == StatePending {
+			return EventCheck, true
+		}
+		return EventStart, true
+	case EventMaxRetriesReached:
+		slog.WarnContext(ctx, "max retries reached", "tx_hash", sm.withdrawal.TxHash,
+			"send_retries", sm.sendRetries, "receipt_retries", sm.receiptRetries, "state", sm.state)
+		// 仍在上链中的交易可能稍后成功，保留预留
+		if sm.state != StatePending {
+			err := sm.transaction(func(tx *gorm.DB) error {
+				if sm.state == StateUnchained {
+					if err := sm.save(ctx, tx, sm.withdrawal.TxHash, model.StateException); err != nil {
+						return err
+					}
+				}
+				return ledger.Release(tx, sm.withdrawal, model.WithdrawalState(sm.withdrawal.Status).String())
+			})
+			if err != nil {
+				return sm.abort(err)
+			}
+		}
+		return event, false
+	case EventSuccess:
+		slog.InfoContext(ctx, "withdrawal executed", "tx_hash", sm.withdrawal.TxHash,
+			"send_retries", sm.sendRetries, "receipt_retries", sm.receiptRetries, "state", sm.state)
+		return event, false
+	case EventCanceled:
+		sm.err = ctx.Err()
+		slog.WarnContext(ctx, "context canceled", "tx_hash", sm.withdrawal.TxHash, "state", sm.state, "err", sm.err)
+		return event, false
+	case EventAborted:
+		slog.WarnContext(ctx, "aborted", "tx_hash", sm.withdrawal.TxHash, "state", sm.state, "err", sm.err)
+		return event, false
+	default:
+		slog.ErrorContext(ctx, "invalid event", "event", event)
+		return event, false
+	}
+}
+
+// prepareTransaction 准备待广播的交易，在持有行锁的事务中保存 tx hash 和上链中状态，提交后再广播
+// outbox 中已有提款申请不知道的交易（广播后进程崩溃、事务回滚），或已保存但还没有广播成功的交易，则重新广播同一笔交易；
+// 否则签名一笔新交易，并在广播前提交到 outbox。
+// 并发执行的请求在行锁上排队，后到的请求版本号不一致返回 repository.ErrConflict，不会再签名一笔交易。
+func (sm *StateMachine) prepareTransaction(ctx context.Context) (*model.Outbox, error) {
+	withdrawalID := uint64(sm.withdrawal.ID)
+	var entry *model.Outbox
+	err := sm.transaction(func(tx *gorm.DB) error {
+		relay := sm.relay.In(tx)
+		var err error
+		entry, err = relay.Latest(withdrawalID)
+		if err != nil {
+			return err
+		}
+		if entry != nil && (entry.TxHash != sm.withdrawal.TxHash || entry.Status == uint64(model.OutboxPending)) {
+			slog.InfoContext(ctx, "rebroadcast outbox transaction", "tx_hash", entry.TxHash, "nonce", entry.Nonce)
+		} else {
+			to := sm.withdrawal.ToAddress
+			if to == "" {
+				to = eth.To
+			}
+			signed, err := sm.client.SignTransaction(ctx, to, sm.withdrawal.AmountBase.BigInt())
+			if err != nil {
+				return err
+			}
+			entry, err = relay.Enqueue(withdrawalID, signed)
+			if err != nil {
+				return err
+			}
+		}
+		return sm.save(ctx, tx, entry.TxHash, model.StatePending)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// recordGas 记录交易实际的 gas 消耗，并汇总到提款申请，随状态一起保存
+// 记录失败不影响状态流转，只打印日志。
+func (sm *StateMachine) recordGas(ctx context.Context, receipt *ethgo.Receipt) {
+	entry, err := outbox.RecordReceipt(sm.db, sm.withdrawal.TxHash, receipt)
+	if err != nil {
+		slog.ErrorContext(ctx, "record gas failed", "tx_hash", sm.withdrawal.TxHash, "err", err)
+		return
+	}
+	total, err := outbox.TotalFee(sm.db, uint64(sm.withdrawal.ID))
+	if err != nil {
+		slog.ErrorContext(ctx, "sum gas fee failed", "tx_hash", sm.withdrawal.TxHash, "err", err)
+		return
+	}
+	sm.withdrawal.GasLimit = entry.GasLimit
+	sm.withdrawal.GasUsed = entry.GasUsed
+	sm.withdrawal.EffectiveGasPrice = entry.EffectiveGasPrice
+	sm.withdrawal.FeeWei = total
+}
+
+// settle 上链成功后在账本中结算，手续费为所有已上链交易的手续费之和
+func (sm *StateMachine) settle(tx *gorm.DB) error {
+	return ledger.Settle(tx, sm.withdrawal, sm.withdrawal.FeeWei.Shift(-18))
+}
+
+// abort 保存失败时结束状态机，由 Execute 返回 err
+func (sm *StateMachine) abort(err error) (TransactionEvent, bool) {
+	sm.err = err
+	return EventAborted, true
+}
+
+func (sm *StateMachine) updateWithdrawalStatus(ctx context.Context, status model.WithdrawalState) error {
+	return sm.transaction(func(tx *gorm.DB) error {
+		return sm.save(ctx, tx, sm.withdrawal.TxHash, status)
+	})
+}
+
+// transaction 在持有提款申请行锁的短事务中执行 fn，提交后即释放行锁
+// 提款申请的版本号与内存中的不一致（已被其他请求修改）时返回 repository.ErrConflict；
+// fn 返回错误时事务回滚，内存中的提款申请恢复为执行前的值。
+func (sm *StateMachine) transaction(fn func(tx *gorm.DB) error) error {
+	saved := *sm.withdrawal
+	err := sm.db.Transaction(func(tx *gorm.DB) error {
+		current, err := sm.withdrawals.Lock(tx, uint64(sm.withdrawal.ID))
+		if err != nil {
+			return err
+		}
+		if current.Version != sm.withdrawal.Version {
+			return repository.ErrConflict
+		}
+		return fn(tx)
+	})
+	if err != nil {
+		*sm.withdrawal = saved
+	}
+	return err
+}
+
+// save 在 tx 中保存 tx hash、状态和 gas 消耗，发生变化时在同一个事务中记录事件
+// 只有版本号和状态与读取时一致才保存。
+func (sm *StateMachine) save(ctx context.Context, tx *gorm.DB, hash string, status model.WithdrawalState) error {
+	from, fromHash := sm.withdrawal.Status, sm.withdrawal.TxHash
+	sm.withdrawal.TxHash = hash
+	sm.withdrawal.Status = uint64(status)
+	err := sm.withdrawals.Update(tx, sm.withdrawal, model.WithdrawalState(from), map[string]interface{}{
+		"tx_hash":             hash,
+		"status":              uint64(status),
+		"gas_limit":           sm.withdrawal.GasLimit,
+		"gas_used":            sm.withdrawal.GasUsed,
+		"effective_gas_price": sm.withdrawal.EffectiveGasPrice,
+		"fee_wei":             sm.withdrawal.FeeWei,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "update withdrawal tx hash and status failed", "tx_hash", hash,
+			"version", sm.withdrawal.Version, "err", err)
+		return err
+	}
+
+	if from == sm.withdrawal.Status && fromHash == hash {
+		return nil
+	}
+	_, err = sm.events.Record(tx, sm.withdrawal, events.TypeStateChanged, map[string]interface{}{
+		"from": model.WithdrawalState(from).String(),
+		"to":   status.String(),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "record withdrawal event failed", "tx_hash", hash, "err", err)
+	}
+	return nil
+}
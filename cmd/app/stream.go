@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"task/cmd/app/apierr"
+	"task/cmd/app/auth"
+	"task/cmd/app/events"
+	"task/cmd/app/model"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	streamHeartbeatInterval = time.Second * 15 // 心跳，避免代理断开空闲连接
+	streamReplayBatch       = 500
+)
+
+// streamEvent SSE 中的事件，Data 原样输出为 JSON 对象
+type streamEvent struct {
+	ID           uint            `json:"id"`
+	CreatedAt    time.Time       `json:"created_at"`
+	WithdrawalID uint64          `json:"withdrawal_id"`
+	Type         string          `json:"type"`
+	State        string          `json:"state"`
+	Status       uint64          `json:"status"`
+	TxHash       string          `json:"tx_hash,omitempty"`
+	Data         json.RawMessage `json:"data,omitempty"`
+}
+
+// registerStreamRoutes 注册 SSE 路由
+// 连接建立后先从事件表补发 Last-Event-ID 之后的事件，再推送新提交的事件。
+func registerStreamRoutes(r *gin.Engine, svc *WithdrawalService, db *gorm.DB, hub *events.Hub) {
+	// 单个提款申请的事件 (GET /withdrawal/{request_id}/events)
+	r.GET("/withdrawal/:request_id/events", func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("request_id"), 10, 64)
+		if err != nil || id == 0 {
+			apierr.Respond(c, apierr.InvalidRequest(err))
+			return
+		}
+
+		// 没有 read-all 权限只能订阅自己发起的
+		err = svc.CanWatch(auth.IdentityFrom(c), id)
+		if err != nil {
+			apierr.Respond(c, err)
+			return
+		}
+
+		streamEvents(c, db, hub, id)
+	})
+
+	// 所有提款申请的事件 (GET /withdrawals/events)
+	r.GET("/withdrawals/events", auth.Require(auth.PermReadAll), func(c *gin.Context) {
+		streamEvents(c, db, hub, 0)
+	})
+}
+
+// streamEvents 以 SSE 推送 withdrawalID 的事件直到客户端断开，withdrawalID 为 0 表示所有提款申请
+func streamEvents(c *gin.Context, db *gorm.DB, hub *events.Hub, withdrawalID uint64) {
+	lastID, _ := strconv.ParseUint(c.GetHeader("Last-Event-ID"), 10, 64)
+	if lastID == 0 {
+		lastID, _ = strconv.ParseUint(c.Query("last_event_id"), 10, 64)
+	}
+
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	send := func(event *model.WithdrawalEvent) error {
+		c.Render(-1, sse.Event{
+			Id:    strconv.FormatUint(uint64(event.ID), 10),
+			Event: event.Type,
+			Data:  newStreamEvent(event),
+		})
+		return nil
+	}
+	heartbeat := func() error {
+		_, err := c.Writer.WriteString(":\n\n")
+		return err
+	}
+	_ = watchEvents(c.Request.Context(), db, hub, withdrawalID, lastID, send, c.Writer.Flush, heartbeat)
+}
+
+// watchEvents 推送 lastID 之后的事件直到 ctx 取消，SSE 和 gRPC 共用
+// 先订阅 Hub 再从事件表补发，补发期间产生的事件不会丢失。事件按提交后分配的 seq 推送和补发，
+// lastID 为客户端收到的最后一个事件 ID，从该事件的 seq 之后继续，较晚提交的 ID 较小的事件不会被跳过。
+// flush 在每批事件之后调用，heartbeat 为 nil 时不发送心跳。
+func watchEvents(
+	ctx context.Context,
+	db *gorm.DB,
+	hub *events.Hub,
+	withdrawalID, lastID uint64,
+	send func(*model.WithdrawalEvent) error,
+	flush func(),
+	heartbeat func() error,
+) error {
+	sub := hub.Subscribe(withdrawalID)
+	defer hub.Unsubscribe(sub)
+
+	lastSeq, err := resumeSeq(db, lastID)
+	if err != nil {
+		slog.ErrorContext(ctx, "find withdrawal event failed", "event_id", lastID, "err", err)
+		return err
+	}
+
+	emit := func(event *model.WithdrawalEvent) error {
+		if event.Seq <= lastSeq {
+			return nil
+		}
+		err := send(event)
+		if err != nil {
+			return err
+		}
+		lastSeq = event.Seq
+		return nil
+	}
+	replay := func() error {
+		for {
+			var logged []*model.WithdrawalEvent
+			query := db.Where("seq > ?", lastSeq)
+			if withdrawalID != 0 {
+				query = query.Where("withdrawal_id = ?", withdrawalID)
+			}
+			err := query.Order("seq").Limit(streamReplayBatch).Find(&logged).Error
+			if err != nil {
+				slog.ErrorContext(ctx, "find withdrawal events failed", "withdrawal_id", withdrawalID, "err", err)
+				return err
+			}
+			for _, event := range logged {
+				if err := emit(event); err != nil {
+					return err
+				}
+			}
+			flush()
+			if len(logged) < streamReplayBatch {
+				return nil
+			}
+		}
+	}
+	err = replay()
+	if err != nil {
+		return err
+	}
+
+	heartbeats := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeats.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-sub.C:
+			if !ok {
+				// 消费过慢被 Hub 关闭，客户端带 Last-Event-ID 重连
+				return nil
+			}
+			if err := emit(event); err != nil {
+				return err
+			}
+			flush()
+		case <-heartbeats.C:
+			if heartbeat == nil {
+				continue
+			}
+			if err := heartbeat(); err != nil {
+				return err
+			}
+			flush()
+		}
+	}
+}
+
+// resumeSeq 事件 lastID 的 seq，事件不存在时取 ID 不大于 lastID 的事件中最大的 seq
+func resumeSeq(db *gorm.DB, lastID uint64) (uint64, error) {
+	if lastID == 0 {
+		return 0, nil
+	}
+	var seq uint64
+	err := db.Model(&model.WithdrawalEvent{}).Where("id = ? AND seq > 0", lastID).Select("seq").Scan(&seq).Error
+	if err != nil || seq != 0 {
+		return seq, err
+	}
+	err = db.Model(&model.WithdrawalEvent{}).Where("id <= ?", lastID).Select("COALESCE(MAX(seq), 0)").Scan(&seq).Error
+	return seq, err
+}
+
+func newStreamEvent(event *model.WithdrawalEvent) *streamEvent {
+	e := &streamEvent{
+		ID:           event.ID,
+		CreatedAt:    event.CreatedAt,
+		WithdrawalID: event.WithdrawalID,
+		Type:         event.Type,
+		State:        model.WithdrawalState(event.Status).String(),
+		Status:       event.Status,
+		TxHash:       event.TxHash,
+	}
+	if event.Data != "" {
+		e.Data = json.RawMessage(event.Data)
+	}
+	return e
+}
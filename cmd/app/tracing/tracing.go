@@ -0,0 +1,139 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation tracer 名称
+const instrumentation = "task/cmd/app"
+
+// Config 链路追踪配置
+type Config struct {
+	Endpoint    string  // TASK_OTLP_ENDPOINT，OTLP/HTTP collector 地址，如 http://otel-collector:4318，为空时不导出
+	ServiceName string  // TASK_OTLP_SERVICE_NAME，默认 task
+	SampleRatio float64 // TASK_TRACE_SAMPLE_RATIO，根 span 的采样比例，默认 1；有上游 trace context 时沿用上游的采样决定
+}
+
+// Setup 设置全局 TracerProvider 和 W3C trace context propagator，返回的函数用于退出前导出剩余的 span
+// Endpoint 为空时不创建 TracerProvider，span 不会被记录，但仍然透传上游的 trace context。
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts, err := exporterOptions(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	name := cfg.ServiceName
+	if name == "" {
+		name = "task"
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(name)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// exporterOptions 解析 collector 地址，路径为空时使用 /v1/traces，http 地址不使用 TLS
+func exporterOptions(endpoint string) ([]otlptracehttp.Option, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid otlp endpoint: %s", endpoint)
+	}
+	path := u.Path
+	if path == "" || path == "/" {
+		path = "/v1/traces"
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(path),
+	}
+	switch u.Scheme {
+	case "http":
+		opts = append(opts, otlptracehttp.WithInsecure())
+	case "https":
+	default:
+		return nil, fmt.Errorf("invalid otlp endpoint scheme: %s", endpoint)
+	}
+	return opts, nil
+}
+
+// Tracer 本服务使用的 tracer
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Start 开始一个 span
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// End 结束 span，err 不为 nil 时记录错误并把状态设为 Error
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject 把 ctx 中的 trace context 写入 carrier，如 HTTP 请求头
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
+
+// Extract 从 carrier 中读取 trace context
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// TraceParent 返回 ctx 中的 W3C traceparent，没有 trace context 时返回空字符串
+// 用于跨进程或异步任务（如 webhook 投递）保存 trace context。
+func TraceParent(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// WithTraceParent 返回带有 traceparent 所表示的远端 span 的 context，traceparent 为空或无效时返回 ctx
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": traceParent})
+}
+
+// Int64 int64 属性，用于 withdrawal_id 等 ID
+func Int64(key string, v uint64) attribute.KeyValue {
+	return attribute.Int64(key, int64(v))
+}
@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"task/cmd/app/model"
+
+	"github.com/gin-gonic/gin"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector 本地 OTLP/HTTP collector，记录收到的 span
+type collector struct {
+	mu    sync.Mutex
+	spans map[string]string // span 名称 -> trace ID
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	req := &coltracepb.ExportTraceServiceRequest{}
+	if r.URL.Path != "/v1/traces" || proto.Unmarshal(body, req) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, span := range ss.Spans {
+				c.spans[span.Name] = traceID(span.TraceId)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(nil)
+}
+
+func traceID(b []byte) string {
+	const hex = "0123456789abcdef"
+	var sb strings.Builder
+	for _, v := range b {
+		sb.WriteByte(hex[v>>4])
+		sb.WriteByte(hex[v&0x0f])
+	}
+	return sb.String()
+}
+
+func TestExport(t *testing.T) {
+	stub := &collector{spans: map[string]string{}}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	shutdown, err := Setup(context.Background(), Config{Endpoint: srv.URL, SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := model.Open(model.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Use(GormPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.POST("/withdrawal/execute/:request_id", func(c *gin.Context) {
+		err := db.WithContext(c.Request.Context()).Exec("CREATE TABLE t (id integer)").Error
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodPost, "/withdrawal/execute/1", nil)
+	req.Header.Set("traceparent", parent)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("traceparent"), "00-4bf92f3577b34da6a3ce929d0e0e4736-") {
+		t.Fatalf("unexpected response: code=%d, traceparent=%q", w.Code, w.Header().Get("traceparent"))
+	}
+
+	// Shutdown 导出剩余的 span
+	if err = shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	for _, name := range []string{"POST /withdrawal/execute/:request_id", "gorm.raw"} {
+		if stub.spans[name] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Fatalf("span %q not exported with inbound trace id: %v", name, stub.spans)
+		}
+	}
+}
+
+func TestTraceParent(t *testing.T) {
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := WithTraceParent(context.Background(), parent)
+	if got := TraceParent(ctx); got != parent {
+		t.Fatalf("expected %s, got %s", parent, got)
+	}
+	if got := TraceParent(context.Background()); got != "" {
+		t.Fatalf("expected empty traceparent, got %s", got)
+	}
+}
+
+func TestExporterOptions(t *testing.T) {
+	for _, endpoint := range []string{"localhost:4318", "ftp://collector:4318", "http://"} {
+		if _, err := exporterOptions(endpoint); err == nil {
+			t.Errorf("expected error for %q", endpoint)
+		}
+	}
+}
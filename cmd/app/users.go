@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"task/cmd/app/apierr"
+	"task/cmd/app/auth"
+	"task/cmd/app/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type UserRequest struct {
+	Name  string      `json:"name"`
+	Roles []auth.Role `json:"roles"`
+}
+
+type UserRolesRequest struct {
+	Roles []auth.Role `json:"roles"`
+}
+
+// registerUserRoutes 注册用户管理路由，需要 manage-users 权限
+func registerUserRoutes(r *gin.Engine, db *gorm.DB) {
+	g := r.Group("/user", auth.Require(auth.PermManageUsers))
+
+	// 创建用户 (POST /user/create)
+	g.POST("/create", func(c *gin.Context) {
+		req := &UserRequest{}
+		err := c.ShouldBindJSON(req)
+		if err != nil {
+			apierr.Respond(c, apierr.InvalidRequest(err))
+			return
+		}
+		if req.Name == "" {
+			apierr.Respond(c, apierr.Field(apierr.CodeValidationFailed, "name", "is required", nil))
+			return
+		}
+		if !validRoles(req.Roles) {
+			apierr.Respond(c, rolesError(req.Roles))
+			return
+		}
+
+		user := &model.User{Name: req.Name}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(user).Error; err != nil {
+				return err
+			}
+			return auth.SetRoles(tx, uint64(user.ID), req.Roles)
+		})
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("create user failed", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "success",
+			"user_id": user.ID,
+		})
+	})
+
+	// 设置用户角色 (POST /user/roles/{user_id})
+	g.POST("/roles/:user_id", func(c *gin.Context) {
+		userID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+		req := &UserRolesRequest{}
+		if err == nil {
+			err = c.ShouldBindJSON(req)
+		}
+		if err != nil {
+			apierr.Respond(c, apierr.InvalidRequest(err))
+			return
+		}
+		if !validRoles(req.Roles) {
+			apierr.Respond(c, rolesError(req.Roles))
+			return
+		}
+
+		err = db.First(&model.User{}, userID).Error
+		if err != nil {
+			apierr.Respond(c, apierr.Wrap(apierr.CodeUserNotFound, "user not found", err))
+			return
+		}
+
+		err = auth.SetRoles(db, userID, req.Roles)
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("set roles failed", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "success",
+		})
+	})
+
+	// 查询所有用户及角色 (GET /user/list)
+	g.GET("/list", func(c *gin.Context) {
+		var users []*model.User
+		err := db.Order("id").Find(&users).Error
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("find user failed", err))
+			return
+		}
+
+		var userRoles []*model.UserRole
+		err = db.Find(&userRoles).Error
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("find user roles failed", err))
+			return
+		}
+
+		roles := make(map[uint64][]string)
+		for _, r := range userRoles {
+			roles[r.UserID] = append(roles[r.UserID], r.Role)
+		}
+		result := make([]gin.H, 0, len(users))
+		for _, user := range users {
+			result = append(result, gin.H{
+				"id":    user.ID,
+				"name":  user.Name,
+				"roles": roles[uint64(user.ID)],
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"users": result,
+		})
+	})
+}
+
+func validRoles(roles []auth.Role) bool {
+	for _, role := range roles {
+		if !auth.ValidRole(role) {
+			return false
+		}
+	}
+	return true
+}
+
+// rolesError 列出未知的角色
+func rolesError(roles []auth.Role) error {
+	e := apierr.New(apierr.CodeValidationFailed, "invalid roles")
+	for _, role := range roles {
+		if !auth.ValidRole(role) {
+			e.Details = append(e.Details, apierr.FieldError{Field: "roles", Message: "unknown role: " + string(role)})
+		}
+	}
+	return e
+}
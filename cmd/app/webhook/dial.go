@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// cgnat 运营商级 NAT 地址段 100.64.0.0/10，net.IP.IsPrivate 不包含
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// forbiddenIP 回环、内网、链路本地（含云厂商元数据地址 169.254.169.254）、未指定和组播地址不允许投递
+func forbiddenIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || cgnat.Contains(ip) ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// dialControl 在建立连接前校验解析后的 IP，注册后域名重新解析到内网地址（DNS rebinding）同样被拒绝
+func dialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || forbiddenIP(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+// newClient 投递使用的 HTTP 客户端，只连接公网地址
+// 不使用环境变量中的代理，否则校验的是代理的地址而不是订阅地址；重定向同样经过 dialControl 校验。
+func newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: time.Second * 5, Control: dialControl}
+	return &http.Client{
+		Timeout: time.Second * 10,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: time.Second * 5,
+			MaxIdleConnsPerHost: 4,
+			IdleConnTimeout:     time.Minute,
+		},
+	}
+}
+
+// forbiddenHost 注册时校验字面 IP 和 localhost；域名在投递建立连接时校验
+func forbiddenHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && forbiddenIP(ip)
+}
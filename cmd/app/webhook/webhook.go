@@ -0,0 +1,433 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"task/cmd/app/events"
+	"task/cmd/app/model"
+	"task/cmd/app/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// 投递请求头
+const (
+	HeaderSignature = "X-Webhook-Signature" // t=<unix 秒>,v1=<hex(HMAC-SHA256(secret, "<unix 秒>.<请求体>"))>
+	HeaderEvent     = "X-Webhook-Event"     // 事件类型
+	HeaderDelivery  = "X-Webhook-Delivery"  // 投递 ID，重新投递时不变的是事件 ID
+)
+
+var (
+	ErrInvalidURL       = errors.New("invalid webhook url")
+	ErrForbiddenAddress = errors.New("webhook address not allowed")
+	ErrInvalidEvent     = errors.New("invalid webhook event")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Backoff 投递失败后的退避策略，main 包的 RetryPolicy 实现了该接口
+type Backoff interface {
+	NextBackoff(attempt int, elapsed time.Duration) (time.Duration, bool)
+}
+
+// Payload 投递的请求体
+type Payload struct {
+	ID         uint64                 `json:"id"` // 事件 ID，接收方可以据此去重
+	Type       string                 `json:"type"`
+	CreatedAt  time.Time              `json:"created_at"`
+	Withdrawal *model.Withdrawal      `json:"withdrawal"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+}
+
+// Sign 计算签名请求头的值
+func Sign(secret string, timestamp int64, body []byte) string {
+	ts := strconv.FormatInt(timestamp, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验签名请求头，供接收方使用；tolerance 为允许的时间偏差，0 表示不校验时间
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var ts int64
+	var sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts, _ = strconv.ParseInt(v, 10, 64)
+		case "v1":
+			sig = v
+		}
+	}
+	if ts == 0 || sig == "" {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 {
+		skew := time.Since(time.Unix(ts, 0))
+		if skew > tolerance || skew < -tolerance {
+			return ErrInvalidSignature
+		}
+	}
+	expected := Sign(secret, ts, body)
+	if !hmac.Equal([]byte(expected), []byte("t="+strconv.FormatInt(ts, 10)+",v1="+sig)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ValidateURL 只允许 http、https 地址，不允许回环、内网等地址
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+	if forbiddenHost(u.Hostname()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, u.Hostname())
+	}
+	return nil
+}
+
+// ParseEvents 校验事件过滤，返回逗号分隔的形式，为空表示订阅全部事件
+func ParseEvents(types []string) (string, error) {
+	for _, t := range types {
+		if !events.Type(t).Valid() {
+			return "", fmt.Errorf("%w: %s", ErrInvalidEvent, t)
+		}
+	}
+	return strings.Join(types, ","), nil
+}
+
+// Matches 订阅是否接收该事件
+func Matches(sub *model.WebhookSubscription, event *model.WithdrawalEvent, withdrawal *model.Withdrawal) bool {
+	if sub.DisabledAt != nil {
+		return false
+	}
+	if !sub.AllWithdrawals && sub.UserID != withdrawal.CreatedBy {
+		return false
+	}
+	if sub.Events == "" {
+		return true
+	}
+	for _, t := range strings.Split(sub.Events, ",") {
+		if t == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribe 创建订阅，返回的订阅中包含签名密钥，只在创建时返回给调用方
+func Subscribe(db *gorm.DB, userID uint64, rawURL string, types []string, allWithdrawals bool) (*model.WebhookSubscription, error) {
+	err := ValidateURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := ParseEvents(types)
+	if err != nil {
+		return nil, err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	sub := &model.WebhookSubscription{
+		UserID:         userID,
+		URL:            rawURL,
+		Secret:         "whsec_" + hex.EncodeToString(secret),
+		Events:         filter,
+		AllWithdrawals: allWithdrawals,
+	}
+	err = db.Create(sub).Error
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Unsubscribe 取消订阅，未投递的 webhook 不再投递
+func Unsubscribe(db *gorm.DB, userID uint64, id uint64) error {
+	result := db.Model(&model.WebhookSubscription{}).
+		Where("id = ? AND user_id = ? AND disabled_at IS NULL", id, userID).
+		Update("disabled_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Dispatcher 投递 webhook
+// 作为 events.Sink 在事件所在的事务中为每个匹配的订阅写入 webhook_deliveries，事务提交后由 Run 在后台投递；
+// 投递失败按 Backoff 退避重试，重试耗尽后移入 webhook_dead_letters。
+type Dispatcher struct {
+	db       *gorm.DB
+	client   *http.Client
+	backoff  Backoff
+	interval time.Duration // 扫描间隔
+	lease    time.Duration // 投递期间占用记录的时长，避免多个实例重复投递
+	batch    int           // 每轮最多投递的记录数
+	now      func() time.Time
+}
+
+var _ events.Sink = (*Dispatcher)(nil)
+
+func NewDispatcher(db *gorm.DB, backoff Backoff) *Dispatcher {
+	return &Dispatcher{
+		db:       db,
+		client:   newClient(),
+		backoff:  backoff,
+		interval: time.Second,
+		lease:    time.Minute,
+		batch:    100,
+		now:      time.Now,
+	}
+}
+
+// Handle 为匹配的订阅写入待投递记录
+// 保存 tx 的 context 中的 trace context，投递请求与触发事件的请求属于同一个 trace。
+func (d *Dispatcher) Handle(tx *gorm.DB, event *model.WithdrawalEvent, withdrawal *model.Withdrawal) error {
+	var subs []*model.WebhookSubscription
+	err := tx.Where("disabled_at IS NULL").Find(&subs).Error
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	for _, sub := range subs {
+		if !Matches(sub, event, withdrawal) {
+			continue
+		}
+		if body == nil {
+			body, err = marshalPayload(event, withdrawal)
+			if err != nil {
+				return err
+			}
+		}
+		err = tx.Create(&model.WebhookDelivery{
+			SubscriptionID: uint64(sub.ID),
+			EventID:        uint64(event.ID),
+			EventType:      event.Type,
+			Payload:        string(body),
+			NextAttemptAt:  d.now(),
+			TraceParent:    tracing.TraceParent(tx.Statement.Context),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func marshalPayload(event *model.WithdrawalEvent, withdrawal *model.Withdrawal) ([]byte, error) {
+	payload := &Payload{
+		ID:         uint64(event.ID),
+		Type:       event.Type,
+		CreatedAt:  event.CreatedAt,
+		Withdrawal: withdrawal,
+	}
+	if event.Data != "" {
+		err := json.Unmarshal([]byte(event.Data), &payload.Data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(payload)
+}
+
+// Run 定期投递到期的 webhook，直到 ctx 取消
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.deliverDue(ctx)
+		}
+	}
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	var deliveries []*model.WebhookDelivery
+	err := d.db.Where("delivered_at IS NULL AND next_attempt_at <= ?", d.now()).
+		Order("id").
+		Limit(d.batch).
+		Find(&deliveries).Error
+	if err != nil {
+		slog.ErrorContext(ctx, "find webhook deliveries failed", "err", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			return
+		}
+		// 先把下次投递时间推后占住记录，其他实例的条件更新不会命中
+		result := d.db.Model(delivery).
+			Where("next_attempt_at = ? AND delivered_at IS NULL", delivery.NextAttemptAt).
+			Update("next_attempt_at", d.now().Add(d.lease))
+		if result.Error != nil {
+			slog.ErrorContext(ctx, "claim webhook delivery failed", "delivery_id", delivery.ID, "err", result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		d.deliver(ctx, delivery)
+	}
+}
+
+// deliver 投递一次并更新记录
+func (d *Dispatcher) deliver(ctx context.Context, delivery *model.WebhookDelivery) {
+	var sub model.WebhookSubscription
+	err := d.db.First(&sub, delivery.SubscriptionID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		slog.ErrorContext(ctx, "find webhook subscription failed", "delivery_id", delivery.ID, "subscription_id", delivery.SubscriptionID, "err", err)
+		return
+	}
+	if err != nil || sub.DisabledAt != nil {
+		// 订阅已取消，丢弃
+		d.db.Delete(delivery)
+		return
+	}
+
+	delivery.Attempts++
+	ctx, span := tracing.Start(tracing.WithTraceParent(ctx, delivery.TraceParent), "webhook.deliver",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			tracing.Int64("webhook.delivery_id", uint64(delivery.ID)),
+			attribute.String("webhook.event_type", delivery.EventType),
+			attribute.Int("webhook.attempt", delivery.Attempts),
+		),
+	)
+	status, sendErr := d.post(ctx, &sub, delivery)
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	tracing.End(span, sendErr)
+	delivery.LastStatus = status
+	if sendErr == nil {
+		now := d.now()
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+		err = d.db.Save(delivery).Error
+		if err != nil {
+			slog.ErrorContext(ctx, "update webhook delivery failed", "delivery_id", delivery.ID, "err", err)
+		}
+		return
+	}
+
+	slog.WarnContext(ctx, "deliver webhook failed", "delivery_id", delivery.ID, "event_id", delivery.EventID,
+		"url", sub.URL, "attempts", delivery.Attempts, "err", sendErr)
+	delivery.LastError = sendErr.Error()
+	wait, ok := d.backoff.NextBackoff(delivery.Attempts, d.now().Sub(delivery.CreatedAt))
+	if ok {
+		delivery.NextAttemptAt = d.now().Add(wait)
+		err = d.db.Save(delivery).Error
+		if err != nil {
+			slog.ErrorContext(ctx, "update webhook delivery failed", "delivery_id", delivery.ID, "err", err)
+		}
+		return
+	}
+
+	// 重试耗尽，移入死信表
+	err = d.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&model.WebhookDeadLetter{
+			SubscriptionID: delivery.SubscriptionID,
+			EventID:        delivery.EventID,
+			EventType:      delivery.EventType,
+			Payload:        delivery.Payload,
+			Attempts:       delivery.Attempts,
+			LastError:      delivery.LastError,
+			LastStatus:     delivery.LastStatus,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(delivery).Error
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "move webhook delivery to dead letter failed", "delivery_id", delivery.ID, "err", err)
+	}
+}
+
+// post 发送签名请求，2xx 视为投递成功，返回 HTTP 状态码
+func (d *Dispatcher) post(ctx context.Context, sub *model.WebhookSubscription, delivery *model.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, d.now().Unix(), body))
+	// W3C trace context，订阅方可以把处理过程挂到同一个 trace
+	tracing.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Redeliver 把订阅人的一条死信重新加入投递队列，返回新的投递记录
+func (d *Dispatcher) Redeliver(userID uint64, deadLetterID uint64) (*model.WebhookDelivery, error) {
+	var delivery *model.WebhookDelivery
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		var dead model.WebhookDeadLetter
+		err := tx.Joins("JOIN webhook_subscriptions ON webhook_subscriptions.id = webhook_dead_letters.subscription_id").
+			Where("webhook_dead_letters.id = ?", deadLetterID).
+			Where("webhook_subscriptions.user_id = ? AND webhook_subscriptions.disabled_at IS NULL", userID).
+			First(&dead).Error
+		if err != nil {
+			return err
+		}
+
+		delivery = &model.WebhookDelivery{
+			SubscriptionID: dead.SubscriptionID,
+			EventID:        dead.EventID,
+			EventType:      dead.EventType,
+			Payload:        dead.Payload,
+			NextAttemptAt:  d.now(),
+		}
+		err = tx.Create(delivery).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&dead).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"task/cmd/app/events"
+	"task/cmd/app/model"
+	"task/cmd/app/tracing"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	header := Sign("secret", time.Now().Unix(), body)
+
+	if err := Verify("secret", header, body, time.Minute); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if err := Verify("other", header, body, time.Minute); err == nil {
+		t.Fatal("expected error for wrong secret")
+	}
+	if err := Verify("secret", header, []byte(`{"id":2}`), time.Minute); err == nil {
+		t.Fatal("expected error for tampered body")
+	}
+	old := Sign("secret", time.Now().Add(-time.Hour).Unix(), body)
+	if err := Verify("secret", old, body, time.Minute); err == nil {
+		t.Fatal("expected error for stale timestamp")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	withdrawal := &model.Withdrawal{CreatedBy: 7}
+	event := &model.WithdrawalEvent{Type: string(events.TypeStateChanged)}
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		sub  model.WebhookSubscription
+		want bool
+	}{
+		{"all events", model.WebhookSubscription{UserID: 7}, true},
+		{"filtered in", model.WebhookSubscription{UserID: 7, Events: "withdrawal.created,withdrawal.state_changed"}, true},
+		{"filtered out", model.WebhookSubscription{UserID: 7, Events: "withdrawal.created"}, false},
+		{"other user", model.WebhookSubscription{UserID: 8}, false},
+		{"all withdrawals", model.WebhookSubscription{UserID: 8, AllWithdrawals: true}, true},
+		{"disabled", model.WebhookSubscription{UserID: 7, DisabledAt: &now}, false},
+	}
+	for _, tt := range tests {
+		if got := Matches(&tt.sub, event, withdrawal); got != tt.want {
+			t.Errorf("%s: Matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := ParseEvents([]string{"withdrawal.unknown"}); err == nil {
+		t.Error("expected error for unknown event type")
+	}
+	if err := ValidateURL("ftp://example.com"); err == nil {
+		t.Error("expected error for non-http url")
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for _, raw := range []string{"https://example.com/hook", "http://203.0.113.7:8080/hook", "https://[2001:db8::1]/hook"} {
+		if err := ValidateURL(raw); err != nil {
+			t.Errorf("%s: unexpected error: %v", raw, err)
+		}
+	}
+	for _, raw := range []string{
+		"http://localhost:8080/hook",
+		"http://api.localhost/hook",
+		"http://127.0.0.1/hook",
+		"http://10.0.0.5/hook",
+		"http://192.168.1.1/hook",
+		"http://100.64.0.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/hook",
+		"http://[::1]/hook",
+		"http://[fd00:ec2::254]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+	} {
+		if err := ValidateURL(raw); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("%s: expected ErrForbiddenAddress, got %v", raw, err)
+		}
+	}
+}
+
+func TestPost(t *testing.T) {
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sub := &model.WebhookSubscription{Secret: "whsec_test"}
+	delivery := &model.WebhookDelivery{
+		ID:        3,
+		EventType: string(events.TypeCreated),
+		Payload:   `{"id":1,"type":"withdrawal.created"}`,
+	}
+
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify(sub.Secret, r.Header.Get(HeaderSignature), body, time.Minute); err != nil {
+			t.Errorf("verify: %v", err)
+		}
+		if r.Header.Get(HeaderEvent) != delivery.EventType || r.Header.Get(HeaderDelivery) != "3" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		if r.Header.Get("traceparent") != traceParent {
+			t.Errorf("expected traceparent %s, got %s", traceParent, r.Header.Get("traceparent"))
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	sub.URL = srv.URL
+
+	// 不导出 span，只设置 W3C propagator
+	if _, err := tracing.Setup(context.Background(), tracing.Config{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := tracing.WithTraceParent(context.Background(), traceParent)
+
+	// 默认客户端不连接回环地址，测试服务器使用其自带的客户端
+	d := NewDispatcher(nil, nil)
+	if _, err := d.post(ctx, sub, delivery); !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("expected loopback to be refused, got %v", err)
+	}
+	d.client = srv.Client()
+	if code, err := d.post(ctx, sub, delivery); err != nil || code != http.StatusOK {
+		t.Fatalf("post: code=%d, err=%v", code, err)
+	}
+
+	status = http.StatusInternalServerError
+	if code, err := d.post(ctx, sub, delivery); err == nil || code != http.StatusInternalServerError {
+		t.Fatalf("expected failure: code=%d, err=%v", code, err)
+	}
+}
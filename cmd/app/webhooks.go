@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"task/cmd/app/apierr"
+	"task/cmd/app/auth"
+	"task/cmd/app/model"
+	"task/cmd/app/webhook"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"` // 订阅的事件类型，为空表示全部
+}
+
+// registerWebhookRoutes 注册 webhook 订阅路由
+// 每个调用方只能管理自己的订阅；有 read-all 权限时订阅所有提款申请的事件，否则只接收自己发起的。
+func registerWebhookRoutes(r *gin.Engine, db *gorm.DB, dispatcher *webhook.Dispatcher) {
+	g := r.Group("/webhook")
+
+	// 创建订阅 (POST /webhook/create)，签名密钥只在创建时返回
+	g.POST("/create", func(c *gin.Context) {
+		req := &WebhookRequest{}
+		err := c.ShouldBindJSON(req)
+		if err != nil {
+			apierr.Respond(c, apierr.InvalidRequest(err))
+			return
+		}
+
+		identity := auth.IdentityFrom(c)
+		sub, err := webhook.Subscribe(db, identity.UserID, req.URL, req.Events, identity.Can(auth.PermReadAll))
+		if errors.Is(err, webhook.ErrInvalidURL) {
+			apierr.Respond(c, apierr.Field(apierr.CodeValidationFailed, "url", "must be an http or https url", err))
+			return
+		}
+		if errors.Is(err, webhook.ErrForbiddenAddress) {
+			apierr.Respond(c, apierr.Field(apierr.CodeValidationFailed, "url", "must not be a loopback, private or link-local address", err))
+			return
+		}
+		if errors.Is(err, webhook.ErrInvalidEvent) {
+			apierr.Respond(c, apierr.Field(apierr.CodeValidationFailed, "events", err.Error(), err))
+			return
+		}
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("create webhook failed", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "success",
+			"webhook_id": sub.ID,
+			"secret":     sub.Secret,
+		})
+	})
+
+	// 查询订阅 (GET /webhook/list)
+	g.GET("/list", func(c *gin.Context) {
+		var subs []*model.WebhookSubscription
+		err := db.Where("user_id = ? AND disabled_at IS NULL", auth.IdentityFrom(c).UserID).
+			Order("id").
+			Find(&subs).Error
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("find webhooks failed", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"webhooks": subs,
+		})
+	})
+
+	// 取消订阅 (DELETE /webhook/{webhook_id})
+	g.DELETE("/:webhook_id", func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("webhook_id"), 10, 64)
+		if err != nil {
+			apierr.Respond(c, apierr.InvalidRequest(err))
+			return
+		}
+
+		err = webhook.Unsubscribe(db, auth.IdentityFrom(c).UserID, id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierr.Respond(c, apierr.Wrap(apierr.CodeWebhookNotFound, "webhook not found", err))
+			return
+		}
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("delete webhook failed", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "success",
+		})
+	})
+
+	// 查询投递失败的 webhook (GET /webhook/dead-letter)
+	g.GET("/dead-letter", func(c *gin.Context) {
+		var dead []*model.WebhookDeadLetter
+		err := db.Joins("JOIN webhook_subscriptions ON webhook_subscriptions.id = webhook_dead_letters.subscription_id").
+			Where("webhook_subscriptions.user_id = ?", auth.IdentityFrom(c).UserID).
+			Order("webhook_dead_letters.id").
+			Find(&dead).Error
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("find webhook dead letters failed", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"dead_letters": dead,
+		})
+	})
+
+	// 重新投递 (POST /webhook/dead-letter/{dead_letter_id}/redeliver)
+	g.POST("/dead-letter/:dead_letter_id/redeliver", func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("dead_letter_id"), 10, 64)
+		if err != nil {
+			apierr.Respond(c, apierr.InvalidRequest(err))
+			return
+		}
+
+		delivery, err := dispatcher.Redeliver(auth.IdentityFrom(c).UserID, id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierr.Respond(c, apierr.Wrap(apierr.CodeDeadLetterNotFound, "dead letter not found", err))
+			return
+		}
+		if err != nil {
+			apierr.Respond(c, apierr.Internal("redeliver webhook failed", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "success",
+			"delivery_id": delivery.ID,
+		})
+	})
+}